@@ -1,3 +1,3 @@
 module github.com/joseluisq/redel/v3
 
-go 1.13
+go 1.23
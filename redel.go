@@ -3,15 +3,89 @@
 // Redel provides a small interface around bufio.Scanner for replace and filter byte occurrences
 // between two byte delimiters. It supports an array of byte-pair replacements
 // with a map and filter closures in order to control every replacement and their values.
+//
+// Every byte slice passed to callbacks is a fresh copy, so it's safe to retain it once the callback returns.
+//
+// A Redel instance is not safe for concurrent use, but separate instances can run concurrently
+// sharing the same delimiters slice, which is never modified. Package-level functions are safe for concurrent use.
 package redel
 
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"iter"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
 )
 
+// EOL is a special end delimiter value which matches the end of a line.
+// The matched value excludes the line ending (`\n` or `\r\n`) and the end of the stream closes the last line too.
+// The line ending is not part of the region, so it's always emitted regardless of the preserve option.
+// It's compared by identity, so a `\n` end value not obtained from this variable is a regular end value.
+var EOL = []byte("\n")
+
+// isEOL checks if an end delimiter value is the `EOL` value.
+func isEOL(value []byte) bool {
+	return len(value) == 1 && &value[0] == &EOL[0]
+}
+
+// ErrEmptyDelimiter is returned when a delimiter has an empty start value or an empty end value without `UntilEOF`.
+var ErrEmptyDelimiter = errors.New("redel: empty start or end delimiter")
+
+// ErrDuplicateDelimiter is returned when a delimiter pair is defined more than once.
+var ErrDuplicateDelimiter = errors.New("redel: duplicate delimiter")
+
+// ErrAmbiguousDelimiter is returned when delimiters have the same start value but different end values.
+var ErrAmbiguousDelimiter = errors.New("redel: start delimiter defined with different end delimiters")
+
+// ErrInputTooLarge is returned when the reader data exceeds the maximum input size.
+var ErrInputTooLarge = errors.New("redel: input exceeds the maximum size")
+
+// ErrProcessorFinished is returned when writing to a processor which is already finished.
+var ErrProcessorFinished = errors.New("redel: processor already finished")
+
+// ErrNotSeeker is returned when seeking a reader which doesn't implement io.Seeker.
+var ErrNotSeeker = errors.New("redel: reader is not an io.Seeker")
+
+// ErrTimeout is returned when a run exceeds the timeout set via `SetTimeout`.
+var ErrTimeout = errors.New("redel: timeout exceeded")
+
+// ErrRegionTooLong is returned when a region doesn't fit in the maximum buffer size.
+// It also wraps `bufio.ErrTooLong` and the start delimiter of the region when it's known.
+var ErrRegionTooLong = errors.New("redel: region exceeds the maximum buffer size")
+
+// ErrNoReader is returned by functions which require the Redel reader when it's `nil`,
+// E.g. for instances created via `NewFromScanner`.
+var ErrNoReader = errors.New("redel: no reader to read from")
+
+// ErrScannerUsed is returned when running an instance created via `NewFromScanner` more than once.
+var ErrScannerUsed = errors.New("redel: scanner already used by a previous run")
+
+// ErrSkipRegion is used as a return value from filter functions to indicate
+// that the current region should be emitted untouched. It's not returned as an error by any function.
+var ErrSkipRegion = errors.New("redel: skip this region")
+
+// Delete is a special replacement value which can be returned by filter functions in order to remove
+// the whole region including its delimiters regardless of the preserve option. It's compared by identity,
+// so an empty slice not obtained from this variable is treated as a regular empty replacement.
+var Delete = make([]byte, 0, 1)
+
+// isDelete checks if a replacement value is the `Delete` value.
+func isDelete(value []byte) bool {
+	return cap(value) > 0 && len(value) == 0 && &value[:1][0] == &Delete[:1][0]
+}
+
 type (
 	// Redel provides an interface (around Scanner) for replace string occurrences
 	// between two string delimiters.
@@ -19,18 +93,66 @@ type (
 		Reader     io.Reader
 		Delimiters []Delimiter
 		eof        []byte
+		bufferSize int
+		bufferMax  int
+		scanner    *givenScanner
+		minLength  int
+		maxLength  int
+		matchFunc  MatchFunc
+		flushSize  int
+		rangeFrom  int
+		rangeTo    int
+		collapse   bool
+		byteCols   bool
+		ctxWindow  int
+		observer   Observer
+		tieBreak   TieBreak
+		maxInput   int64
+		lineEnd    LineEnding
+		filterDels bool
+		multi      *multiReader
+		trailing   TrailingNewline
+		positions  bool
+		emptyRepl  []byte
+		skipEmpty  bool
+		spaceEmpty bool
+		logger     Logger
+		timeout    time.Duration
+		activeFrom int64
+		activeTo   int64
+		nesting    Nesting
+		textFunc   TextReplaceFunc
+		bytesRead  int64
+		stages     []stage
+		transform  ValueTransformFunc
+		baseOffset int64
+		oversized  bool
 	}
 
 	// Delimiter defines a replacement delimiters structure
 	Delimiter struct {
 		Start []byte
 		End   []byte
-	}
-
-	// replacementData interface contains intern replacing info.
-	replacementData struct {
-		delimiter Delimiter
-		value     []byte
+		// Replacement is an optional replacement value used by `ReplacePerDelimiter`
+		Replacement []byte
+		// WordBoundary makes start and end values match only when their edge word characters ([A-Za-z0-9_])
+		// are not next to other word characters
+		WordBoundary bool
+		// UntilEOF makes an empty end value match the end of the stream, so the region value
+		// is the whole remaining data after the start value. Otherwise an empty end value is not valid
+		UntilEOF bool
+		// Greedy makes the start value pair with the last end value of the stream instead of the next one
+		Greedy bool
+		// AltEnds are optional alternative end values. The region is closed by the nearest end value
+		// and the longest one wins at the same position. They are not supported by greedy delimiters
+		AltEnds [][]byte
+		// Replace is an optional replace function used by `ReplaceDispatch`
+		Replace func(value []byte) []byte
+		// AnchorStart makes the start value match only at the very beginning of the stream
+		AnchorStart bool
+		// AnchorEnd makes the end value match only at the very end of the stream.
+		// Note that the data after its start value is kept buffered until the end of the stream
+		AnchorEnd bool
 	}
 
 	// earlyDelimiter defines a found delimiter
@@ -39,24 +161,322 @@ type (
 		delimiter  Delimiter
 		startIndex int
 		endIndex   int
+		fromIndex  int
+		toIndex    int
+		delIndex   int
+		// before and after are the context bytes around the region
+		before []byte
+		after  []byte
+	}
+
+	// scanToken defines a scanned token which contains some text and optionally a matched region at its end.
+	scanToken struct {
+		data   []byte
+		region *earlyDelimiter
+		atEOF  bool
+		// offset is the byte offset of the token in the stream
+		offset int64
+		// line and column are the position of the region start delimiter (or of the token without region)
+		// which are only tracked on demand
+		line   int
+		column int
+		// unclosed are the indexes of the delimiters whose start values are emitted as text in the token
+		// since their end values were not found
+		unclosed []int
+	}
+
+	// givenScanner defines a scanner given via `NewFromScanner`, which can't be rewound after a run.
+	// It's shared by the copies of an instance, so they know whether it was used.
+	givenScanner struct {
+		scanner *bufio.Scanner
+		used    bool
+	}
+
+	// readCloser defines a replaced reader which also closes its underlying read closer.
+	readCloser struct {
+		*io.PipeReader
+		closer io.Closer
+	}
+
+	// Processor replaces every occurrence of the data written in chunks returning the replaced data incrementally.
+	// Regions can span several chunks since partial delimiters are kept between writes.
+	Processor struct {
+		chunks   chan []byte
+		ready    chan struct{}
+		done     chan error
+		output   []byte
+		pending  []byte
+		finished bool
+	}
+
+	// processorWriter writes the data replaced by a processor to an underlying writer.
+	processorWriter struct {
+		proc *Processor
+		w    io.Writer
+	}
+
+	// processorReader reads the chunks written to a processor.
+	processorReader struct {
+		proc *Processor
+	}
+
+	// multiReader reads several readers sequentially keeping the offset where every reader ends.
+	multiReader struct {
+		readers []io.Reader
+		ends    []int64
+		read    int64
+	}
+
+	// limitedReader reads up to a maximum number of bytes returning `ErrInputTooLarge` when there are more.
+	limitedReader struct {
+		reader    io.Reader
+		remaining int64
+	}
+
+	// position tracks the line and column numbers (1-based) of the scanned data.
+	position struct {
+		line        int
+		column      int
+		byteColumns bool
+	}
+
+	// stage defines a delimiter set with its replacement applied by `RunStaged`.
+	stage struct {
+		delimiters  []Delimiter
+		replacement []byte
+	}
+
+	// Rule defines a delimiter with its own replacement and preserve setting.
+	Rule struct {
+		Delimiter   Delimiter
+		Replacement []byte
+		Preserve    bool
+	}
+
+	// Event defines an emitted part of the replaced data.
+	Event struct {
+		// Data is the emitted data, either text passed through or a replacement
+		Data []byte
+		// Replaced reports whether the data is the replacement of a region
+		Replaced bool
+		// Value is the matched value of a replaced region
+		Value []byte
+	}
+
+	// OffsetPair defines the byte offsets of a replaced region (including its delimiters) in the input
+	// and the byte offsets of its replacement in the output. End offsets are exclusive.
+	OffsetPair struct {
+		InputStart  int64
+		InputEnd    int64
+		OutputStart int64
+		OutputEnd   int64
+	}
+
+	// Match defines a matched region.
+	Match struct {
+		// Value is the matched value without delimiters
+		Value []byte
+		// Delimiter is the delimiter pair which matched the value
+		Delimiter Delimiter
+		// Index is the zero-based index of the region in stream order
+		Index int
+		// Offset is the byte offset of the region start delimiter in the stream
+		Offset int64
+		// Line is the line number (1-based) of the region start delimiter
+		Line int
+		// Column is the column number (1-based) of the region start delimiter
+		Column int
+		// Source is the zero-based index of the reader where the region start delimiter begins
+		// for instances created via `NewMulti`, otherwise it's zero
+		Source int
 	}
 
 	// ReplacementMapFunc defines a map function that will be called for every scan splitted token.
 	ReplacementMapFunc func(data []byte, atEOF bool)
 
+	// MatchFunc defines a function that will be called once per replaced region
+	// with its matched value and its replacement value.
+	MatchFunc func(value []byte, replacement []byte)
+
+	// TextFunc defines a function that will be called with the data passed through unchanged.
+	TextFunc func(data []byte)
+
+	// ValueTransformFunc defines a function which transforms every matched value before filtering it.
+	ValueTransformFunc func(value []byte) []byte
+
+	// TextReplaceFunc defines a function which rewrites the text passed through between regions.
+	TextReplaceFunc func(data []byte) []byte
+
+	// ReplacementMapUntilFunc defines a map function that will be called for every scan splitted token
+	// which supports a return `bool` value to continue (`true`) or to halt (`false`) the scanning.
+	ReplacementMapUntilFunc func(data []byte, atEOF bool) bool
+
 	// FilterValueFunc defines a filter function that will be called per replacement
 	// which supports a return `bool` value to apply the replacement or not.
 	FilterValueFunc func(matchValue []byte) bool
 
+	// FilterFunc defines a filter function that will be called per replacement with its matched delimiter
+	// and its zero-based match index which supports a return `bool` value to apply the replacement or not.
+	FilterFunc func(value []byte, delimiter Delimiter, index int) bool
+
 	// FilterValueReplaceFunc defines a filter function that will be called per replacement
 	// which supports a return `[]byte` value to customize the replacement value.
 	FilterValueReplaceFunc func(matchValue []byte) []byte
+
+	// FilterValueReplacePreserveFunc defines a filter function that will be called per replacement
+	// which returns the replacement value and whether the delimiters of the region are kept.
+	FilterValueReplacePreserveFunc func(matchValue []byte) (replacement []byte, preserve bool)
+
+	// FilterValueReplaceErrFunc defines a filter function that will be called per replacement
+	// which supports a return `[]byte` value to customize the replacement value and an `error` value
+	// to abort the whole operation. Return `ErrSkipRegion` in order to keep the region untouched instead.
+	FilterValueReplaceErrFunc func(matchValue []byte) ([]byte, error)
+
+	// FilterWithContextFunc defines a filter function that will be called per replacement
+	// with the context bytes before its start delimiter and after its end delimiter
+	// which supports a return `[]byte` value to customize the replacement value.
+	FilterWithContextFunc func(value []byte, before []byte, after []byte) []byte
+
+	// FilterValueReplaceIndexFunc defines a filter function that will be called per replacement
+	// with its zero-based match index which supports a return `[]byte` value to customize the replacement value.
+	FilterValueReplaceIndexFunc func(matchValue []byte, index int) []byte
+
+	// FilterValueReplaceOffsetFunc defines a filter function that will be called per replacement
+	// with the zero-based byte offset of its value in the input which supports a return `[]byte` value to customize the replacement value.
+	FilterValueReplaceOffsetFunc func(value []byte, offset int64) []byte
+
+	// Observer defines an interface in order to observe the work done while scanning.
+	// OnBytes is called with the size of every scanned token, OnMatch once per matched region
+	// and OnReplace once per replaced region with its matched value and its replacement value.
+	Observer interface {
+		OnMatch(delimiter Delimiter, value []byte)
+		OnReplace(value []byte, replacement []byte)
+		OnBytes(n int)
+	}
+
+	// Logger defines an interface in order to log the decisions taken while replacing.
+	Logger interface {
+		Logf(format string, args ...any)
+	}
+
+	// EscapeFunc defines a function which escapes replacement values before emitting them.
+	EscapeFunc func(value []byte) []byte
+
+	// LineEnding defines how line endings of the text passed through are normalized.
+	LineEnding uint8
+
+	// TrailingNewline defines how the line endings at the end of the replaced data are handled.
+	TrailingNewline uint8
+
+	// Nesting defines how start values inside a region of the same delimiter are handled.
+	Nesting uint8
+
+	// TieBreak defines which region wins when regions of several delimiters start at the same position.
+	TieBreak uint8
+
+	// Preserve defines which delimiters of every matched region are kept on replacement.
+	Preserve uint8
+
+	// regionMatch defines the info of a matched region passed to intern filter functions.
+	regionMatch struct {
+		value          []byte
+		delimiter      Delimiter
+		delimiterIndex int
+		index          int
+		before         []byte
+		after          []byte
+		offset         int64
+		line           int
+		column         int
+		// input is the value passed to user filter functions which can include the delimiters
+		input []byte
+		// preserve can be changed by filter functions in order to override the preserve option of the region
+		preserve Preserve
+		// replace can be set to `false` by filter functions in order to keep the region value instead of replacing it
+		replace bool
+	}
+
+	// filterMatchFunc defines the intern filter function called per replacement with its match info.
+	filterMatchFunc func(match *regionMatch) ([]byte, error)
+
+	// replacedToken is a scanned token once replaced, which is passed to the emit function of `replaceTokens`.
+	replacedToken struct {
+		// data is the replaced data of the token
+		data []byte
+		// textLen is the length of the text before the replaced region, the whole data length without it
+		textLen int
+		// replaced reports whether the token contains a replaced region
+		replaced bool
+		// value and replacement are the matched value and the replacement value of the replaced region
+		value       []byte
+		replacement []byte
+		// inputStart and inputEnd are the input offsets of the whole region including its delimiters
+		inputStart int64
+		inputEnd   int64
+		atEOF      bool
+	}
+)
+
+const (
+	// LineEndingKeep (default) keeps line endings as they are.
+	LineEndingKeep LineEnding = iota
+	// LineEndingLF converts `\r\n` line endings into `\n`.
+	LineEndingLF
+	// LineEndingCRLF converts `\n` line endings into `\r\n`.
+	LineEndingCRLF
+)
+
+const (
+	// TrailingNewlineAsInput (default) keeps the line endings at the end of the data as they are.
+	TrailingNewlineAsInput TrailingNewline = iota
+	// TrailingNewlineAlways makes the data end with exactly one line ending.
+	TrailingNewlineAlways
+	// TrailingNewlineNever removes every line ending at the end of the data.
+	TrailingNewlineNever
 )
 
-// getEOFToken generates a random EOF bytes token.
+const (
+	// TieBreakLongest (default) makes the region with the longest, most specific, start delimiter win
+	// and ties are won by the delimiter declared first.
+	TieBreakLongest TieBreak = iota
+	// TieBreakValueStart makes the region whose value starts first win,
+	// so the shorter start delimiter wins and ties are won by the delimiter declared first.
+	TieBreakValueStart
+	// TieBreakDeclared makes the region of the delimiter declared first win.
+	TieBreakDeclared
+)
+
+const (
+	// NestingFlat (default) pairs the first start value with the first subsequent end value,
+	// so nested start values are part of the region value.
+	NestingFlat Nesting = iota
+	// NestingBalanced pairs every start value with its balanced end value, so nested regions are part of the outer region value.
+	NestingBalanced
+)
+
+const (
+	// PreserveNone removes both start and end delimiters.
+	PreserveNone Preserve = 0
+	// PreserveStart keeps only the start delimiter.
+	PreserveStart Preserve = 1 << 0
+	// PreserveEnd keeps only the end delimiter.
+	PreserveEnd Preserve = 1 << 1
+	// PreserveBoth keeps both start and end delimiters.
+	PreserveBoth = PreserveStart | PreserveEnd
+)
+
+// preserveFromBool converts a `preserveDelimiters` boolean value into its Preserve option.
+func preserveFromBool(preserveDelimiters bool) Preserve {
+	if preserveDelimiters {
+		return PreserveBoth
+	}
+
+	return PreserveNone
+}
+
+// getEOFToken generates a random EOF bytes token. It panics if random bytes can not be generated.
 func getEOFToken() []byte {
-	eof := make([]byte, 7)
-	_, err := rand.Read(eof)
+	eof, err := newEOFToken()
 
 	if err != nil {
 		panic(err)
@@ -65,6 +485,38 @@ func getEOFToken() []byte {
 	return eof
 }
 
+// newEOFToken generates a random EOF bytes token.
+func newEOFToken() ([]byte, error) {
+	eof := make([]byte, 7)
+
+	if _, err := rand.Read(eof); err != nil {
+		return nil, err
+	}
+
+	return eof, nil
+}
+
+// Pair returns a delimiter with the given start and end values.
+func Pair(start string, end string) Delimiter {
+	return Delimiter{Start: []byte(start), End: []byte(end)}
+}
+
+// Delimiters returns the delimiters given by alternating start and end values.
+// It panics if the number of values is odd.
+func Delimiters(pairs ...string) []Delimiter {
+	if len(pairs)%2 != 0 {
+		panic("redel: Delimiters requires an even number of start and end values")
+	}
+
+	delimiters := make([]Delimiter, 0, len(pairs)/2)
+
+	for i := 0; i < len(pairs); i += 2 {
+		delimiters = append(delimiters, Pair(pairs[i], pairs[i+1]))
+	}
+
+	return delimiters
+}
+
 // New creates a new Redel instance.
 func New(reader io.Reader, delimiters []Delimiter) *Redel {
 	eof := getEOFToken()
@@ -76,197 +528,2828 @@ func New(reader io.Reader, delimiters []Delimiter) *Redel {
 	}
 }
 
-// replaceFilterFunc is the API function which scans and replace bytes supporting different options.
-// It's used by API's replace functions.
-func (rd *Redel) replaceFilterFunc(
-	replacementMapFunc ReplacementMapFunc,
-	filterFunc FilterValueReplaceFunc,
-	preserveDelimiters bool,
-	replaceWith bool,
-	replacement []byte,
-) {
-	scanner := bufio.NewScanner(rd.Reader)
-	delimiters := rd.Delimiters
+// NewValidated creates a new Redel instance validating its delimiters first.
+// It returns `ErrEmptyDelimiter` for empty values, `ErrDuplicateDelimiter` for duplicate pairs
+// and `ErrAmbiguousDelimiter` for equal start values with different end values.
+// Start delimiters which are prefixes of other start delimiters are allowed since the tie-break mode resolves them.
+func NewValidated(reader io.Reader, delimiters []Delimiter) (*Redel, error) {
+	if err := validateDelimiters(delimiters); err != nil {
+		return nil, err
+	}
 
-	var valuesData []replacementData
+	return New(reader, delimiters), nil
+}
 
-	ScanByDelimiters := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		var earlyDelimiters []earlyDelimiter
-		var closerDelimiter earlyDelimiter
+// NewSafe creates a new Redel instance like `NewValidated` but it never panics,
+// so it also returns an error if the random EOF token can not be generated.
+func NewSafe(reader io.Reader, delimiters []Delimiter) (*Redel, error) {
+	if err := validateDelimiters(delimiters); err != nil {
+		return nil, err
+	}
 
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
+	eof, err := newEOFToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Redel{
+		Reader:     reader,
+		Delimiters: delimiters,
+		eof:        eof,
+	}, nil
+}
+
+// validateDelimiter checks that a delimiter has no empty values. An empty end value is valid only with `UntilEOF`.
+func validateDelimiter(del Delimiter) error {
+	if len(del.Start) == 0 || (len(del.End) == 0 && !del.UntilEOF) {
+		return ErrEmptyDelimiter
+	}
+
+	for _, end := range del.AltEnds {
+		if len(end) == 0 {
+			return ErrEmptyDelimiter
 		}
+	}
 
-		// iterate array of delimiters
-		for _, del := range delimiters {
-			startLen := len(del.Start)
-			endLen := len(del.End)
+	return nil
+}
+
+// validateDelimiters checks that delimiters have no empty values, no duplicates and no ambiguous start values.
+func validateDelimiters(delimiters []Delimiter) error {
+	for i, del := range delimiters {
+		if err := validateDelimiter(del); err != nil {
+			return err
+		}
 
-			if startLen <= 0 || endLen <= 0 {
+		for _, prev := range delimiters[:i] {
+			if !bytes.Equal(del.Start, prev.Start) {
 				continue
 			}
 
-			// store every found delimiter
-			if from := bytes.Index(data, del.Start); from >= 0 {
-				if to := bytes.Index(data[from:], del.End); to >= 0 {
-					x1 := from + startLen
-					x2 := from + endLen + (to - endLen)
-					val := data[x1:x2]
-
-					earlyDelimiters = append(earlyDelimiters, earlyDelimiter{
-						value:      val,
-						delimiter:  del,
-						startIndex: x1,
-						endIndex:   x2,
-					})
-				}
+			if bytes.Equal(del.End, prev.End) {
+				return ErrDuplicateDelimiter
 			}
+
+			return ErrAmbiguousDelimiter
 		}
+	}
 
-		if len(earlyDelimiters) > 0 {
-			// Determine the closer delimiter
-			for i, del := range earlyDelimiters {
-				if i == 0 || del.startIndex < closerDelimiter.startIndex {
-					closerDelimiter = del
-				}
-			}
+	return nil
+}
 
-			// Assign and check the closer delimiter
-			delimiter := closerDelimiter.delimiter
-			delimiterVal := closerDelimiter.value
+// NewMulti creates a new Redel instance which reads several readers as a single stream,
+// so regions can span several readers. Matches report the reader where they begin via their `Source` field.
+func NewMulti(readers []io.Reader, delimiters []Delimiter) *Redel {
+	multi := &multiReader{readers: readers}
 
-			if len(delimiterVal) > 0 {
-				valuesData = append(valuesData, replacementData{
-					delimiter: delimiter,
-					value:     delimiterVal,
-				})
-			}
+	rd := New(multi, delimiters)
+	rd.multi = multi
 
-			endIndex := closerDelimiter.endIndex
-			startIndex := closerDelimiter.startIndex
+	return rd
+}
 
-			return endIndex, data[0:startIndex], nil
-		}
+// Read reads the current reader moving to the next one at its end.
+func (mr *multiReader) Read(p []byte) (int, error) {
+	for len(mr.readers) > 0 {
+		n, err := mr.readers[0].Read(p)
+		mr.read += int64(n)
 
-		if atEOF && len(data) > 0 {
-			last := append(data[0:], rd.eof...)
-			return len(data), last, nil
+		if err == io.EOF {
+			mr.readers = mr.readers[1:]
+			mr.ends = append(mr.ends, mr.read)
+			err = nil
 		}
 
-		return 0, nil, nil
+		if n > 0 || err != nil {
+			return n, err
+		}
 	}
 
-	scanner.Split(ScanByDelimiters)
+	return 0, io.EOF
+}
 
-	// Variables to control delimiters checking
-	hasStartPrevDelimiter := false
-	var previousDelimiter Delimiter
+// source returns the zero-based index of the reader which contains a stream offset.
+func (mr *multiReader) source(offset int64) int {
+	for i, end := range mr.ends {
+		if offset < end {
+			return i
+		}
+	}
 
-	// Scan every token based on current split function
-	for scanner.Scan() {
-		bytesO := scanner.Bytes()
-		bytesR := make([]byte, len(bytesO))
-		copy(bytesR, bytesO)
+	return len(mr.ends)
+}
 
-		atEOF := bytes.HasSuffix(bytesR, rd.eof)
+// NewFromScanner creates a new Redel instance using an already configured Scanner.
+// Its split function is replaced but its buffer configuration is respected.
+// Note that the caller must not call `Scan` on the scanner beforehand.
+// Since the maximum buffer size of the scanner is unknown, `SetOversizedAsText` has no effect. The scanner can't be rewound, so the instance supports a single run
+// and further runs return `ErrScannerUsed` (E.g. `Count` followed by `ReplaceAll`) until calling `Reset`.
+func NewFromScanner(scanner *bufio.Scanner, delimiters []Delimiter) *Redel {
+	rd := New(nil, delimiters)
+	rd.scanner = &givenScanner{scanner: scanner}
 
-		valueCurrent := []byte(nil)
-		valueCurrentLen := len(valuesData) - 1
-		valueToReplace := []byte(nil)
+	return rd
+}
 
-		var replacementData replacementData
+// AddDelimiter appends a new delimiter pair. It returns `ErrEmptyDelimiter` if any value is empty.
+// Note that adding delimiters once a scan has begun is not supported.
+func (rd *Redel) AddDelimiter(start []byte, end []byte) error {
+	return rd.AddDelimiters(Delimiter{Start: start, End: end})
+}
 
-		if valueCurrentLen >= 0 {
-			replacementData = valuesData[valueCurrentLen]
-			valueCurrent = append(valueCurrent, replacementData.value...)
-			valueToReplace = filterFunc(valueCurrent)
+// AddDelimiters appends new delimiters. It returns `ErrEmptyDelimiter` without appending any delimiter
+// if some of them has an empty value. Note that adding delimiters once a scan has begun is not supported.
+func (rd *Redel) AddDelimiters(delimiters ...Delimiter) error {
+	for _, del := range delimiters {
+		if err := validateDelimiter(del); err != nil {
+			return err
 		}
+	}
 
-		delimiterData := replacementData.delimiter
+	// The delimiters slice is copied on growth so a slice shared with other instances is never modified
+	rd.Delimiters = append(rd.Delimiters[:len(rd.Delimiters):len(rd.Delimiters)], delimiters...)
 
-		// Remove delimiters only if `preserveDelimiters` is `false`
-		if !preserveDelimiters {
-			// 1. Check for the first start delimiter (once)
-			if !hasStartPrevDelimiter && bytes.HasSuffix(bytesR, delimiterData.Start) {
-				bytesR = bytes.Replace(bytesR, delimiterData.Start, []byte(nil), 1)
-				previousDelimiter = delimiterData
-				hasStartPrevDelimiter = true
-			}
+	return nil
+}
 
-			// 2. Next check for start and end delimiters (many times)
-			if hasStartPrevDelimiter {
-				hasPrevEndDelimiter := false
+// Reset resets the Redel instance in order to process a new reader.
+// Delimiters and configured options are kept but a scanner given via `NewFromScanner` or readers given via `NewMulti` are discarded.
+func (rd *Redel) Reset(reader io.Reader) {
+	rd.Reader = reader
+	rd.scanner = nil
+	rd.multi = nil
+}
 
-				// 2.1. Check for a previous end delimiter (in current data)
-				if bytes.HasPrefix(bytesR, previousDelimiter.End) {
-					bytesR = bytes.Replace(bytesR, previousDelimiter.End, []byte(nil), 1)
-					previousDelimiter = delimiterData
-					hasPrevEndDelimiter = true
-				}
+// SetBufferSize sets the initial buffer size and the maximum buffer size used while scanning.
+// See bufio.Scanner.Buffer for more details.
+//
+// The maximum buffer size must be large enough to hold the longest matched region including its delimiters,
+// otherwise the scanning stops with `ErrRegionTooLong` unless `SetOversizedAsText` is enabled.
+// The initial size can be smaller than the delimiters since the buffer grows as needed up to the maximum size.
+// It has no effect on instances created via `NewFromScanner`.
+func (rd *Redel) SetBufferSize(size int, max int) {
+	rd.bufferSize = size
+	rd.bufferMax = max
+}
 
-				// 2.2. Check for a new start delimiter (in current data)
-				if hasPrevEndDelimiter && bytes.HasSuffix(bytesR, delimiterData.Start) {
-					bytesR = bytes.Replace(bytesR, delimiterData.Start, []byte(nil), 1)
-				}
-			}
-		}
+// SetOversizedAsText sets whether a start delimiter whose region doesn't fit in the maximum buffer size
+// (E.g. a start delimiter which is never closed) is given up and emitted as text once the buffer is full,
+// so the scanning continues after it instead of stopping with `ErrRegionTooLong` (default).
+// Note that the value of such a region is emitted unchanged, so it's not suitable for redacting data.
+// It has no effect on instances created via `NewFromScanner` since their maximum buffer size is unknown.
+func (rd *Redel) SetOversizedAsText(asText bool) {
+	rd.oversized = asText
+}
 
-		// Last process to append or not values or replacements
-		if atEOF {
-			bytesR = bytes.Split(bytesR, rd.eof)[0]
-		} else {
-			if replaceWith {
-				// takes the callback value instead
-				bytesR = append(bytesR, valueToReplace...)
-			} else {
-				// don't replace and use the value instead
-				if len(valueToReplace) == 0 {
-					// takes the array value instead
-					bytesR = append(bytesR, valueCurrent...)
-				} else {
-					// otherwise use the replacement value
-					bytesR = append(bytesR, replacement...)
-				}
-			}
-		}
+// SetValueLengthBounds sets the minimum and maximum length of the values to be considered matches.
+// Regions with values out of these bounds are emitted verbatim. A `max` value lower or equal to zero means no upper bound.
+func (rd *Redel) SetValueLengthBounds(min int, max int) {
+	rd.minLength = min
+	rd.maxLength = max
+}
 
-		replacementMapFunc(bytesR, atEOF)
+// isValueLengthInBounds checks if a value length is within the configured bounds.
+func (rd *Redel) isValueLengthInBounds(length int) bool {
+	if length < rd.minLength {
+		return false
 	}
+
+	return rd.maxLength <= 0 || length <= rd.maxLength
 }
 
-// Replace function replaces every occurrence with a custom replacement token.
-func (rd *Redel) Replace(replacement []byte, mapFunc ReplacementMapFunc) {
-	rd.replaceFilterFunc(mapFunc, func(value []byte) []byte {
-		return value
-	}, false, false, replacement)
+// SetMatchFunc sets a function that will be called once per replaced region
+// with its matched value and its replacement value, before calling the map function with the region.
+func (rd *Redel) SetMatchFunc(matchFunc MatchFunc) {
+	rd.matchFunc = matchFunc
 }
 
-// ReplaceFilter function scans and replaces byte occurrences filtering every replacement value via a bool callback.
-func (rd *Redel) ReplaceFilter(
-	replacement []byte,
-	mapFunc ReplacementMapFunc,
-	filterFunc FilterValueFunc,
-	preserveDelimiters bool,
-) {
-	rd.replaceFilterFunc(mapFunc, func(matchValue []byte) []byte {
-		result := []byte(nil)
+// SetFlushThreshold sets the minimum size of the data passed to map functions.
+// Replaced data is coalesced until reaching that size and the remaining data is passed at the end.
+// A value lower or equal to zero (default) passes every scanned token as it is.
+func (rd *Redel) SetFlushThreshold(n int) {
+	rd.flushSize = n
+}
+
+// SetReplaceRange sets the range of regions to be replaced using their 1-based index in stream order.
+// Regions out of the range are emitted verbatim and they are not passed to filter functions.
+// A `to` value lower or equal to zero means no upper bound.
+func (rd *Redel) SetReplaceRange(from int, to int) {
+	rd.rangeFrom = from
+	rd.rangeTo = to
+}
 
-		ok := filterFunc(matchValue)
+// SetActiveRange sets the byte range `[start, end)` of the input where regions are replaced.
+// Regions whose start delimiter offset is out of the range are emitted verbatim and they are not passed to filter functions.
+// An `end` value lower or equal to zero means no upper bound.
+func (rd *Redel) SetActiveRange(start int64, end int64) {
+	rd.activeFrom = start
+	rd.activeTo = end
+}
 
-		if ok {
-			result = []byte("1")
-		}
+// isInReplaceRange checks if a region zero-based index and its byte offset are within the replace and active ranges.
+func (rd *Redel) isInReplaceRange(index int, offset int64) bool {
+	if offset < rd.activeFrom || (rd.activeTo > 0 && offset >= rd.activeTo) {
+		return false
+	}
 
-		return result
-	}, preserveDelimiters, false, replacement)
+	n := index + 1
+
+	if n < rd.rangeFrom {
+		return false
+	}
+
+	return rd.rangeTo <= 0 || n <= rd.rangeTo
 }
 
-// ReplaceFilterWith function scans and replaces byte occurrences via a custom replacement callback.
-func (rd *Redel) ReplaceFilterWith(
-	mapFunc ReplacementMapFunc,
-	filterReplaceFunc FilterValueReplaceFunc,
-	preserveDelimiters bool,
-) {
-	rd.replaceFilterFunc(mapFunc, filterReplaceFunc, preserveDelimiters, true, []byte(nil))
+// SetCollapseAdjacent sets whether consecutive equal replacements separated only by
+// whitespace characters (space, \t, \n, \v, \f and \r) or by nothing are collapsed into the first one,
+// dropping the whitespace characters between them too.
+func (rd *Redel) SetCollapseAdjacent(collapse bool) {
+	rd.collapse = collapse
+}
+
+// SetByteColumns sets whether match column numbers count bytes instead of UTF-8 runes (default).
+func (rd *Redel) SetByteColumns(byteColumns bool) {
+	rd.byteCols = byteColumns
+}
+
+// SetContextWindow sets the number of context bytes passed to context filter functions
+// before the start delimiter and after the end delimiter of every region.
+// Context bytes are taken from the original data, not from the replaced one.
+// Near the beginning or the end of the stream fewer bytes are available, so context values can be shorter or empty.
+// Note that the maximum buffer size must also hold the context bytes after the region.
+func (rd *Redel) SetContextWindow(n int) {
+	rd.ctxWindow = n
+}
+
+// SetEmptyValueReplacement sets a replacement used for regions with an empty value instead of
+// calling the filter functions. A `nil` replacement (default) handles empty regions like any other one.
+func (rd *Redel) SetEmptyValueReplacement(replacement []byte) {
+	rd.emptyRepl = replacement
+}
+
+// SetSkipEmptyValues sets whether regions with an empty value are left unchanged without calling the filter functions.
+// It takes precedence over the empty value replacement.
+func (rd *Redel) SetSkipEmptyValues(skip bool) {
+	rd.skipEmpty = skip
+}
+
+// SetWhitespaceAsEmpty sets whether region values with only whitespace characters
+// (space, \t, \n, \v, \f and \r) are considered empty. By default only zero-length values are empty.
+func (rd *Redel) SetWhitespaceAsEmpty(whitespaceAsEmpty bool) {
+	rd.spaceEmpty = whitespaceAsEmpty
+}
+
+// isEmptyValue checks if a region value is considered empty.
+func (rd *Redel) isEmptyValue(value []byte) bool {
+	return len(value) == 0 || (rd.spaceEmpty && isSpaceBytes(value))
+}
+
+// emptyValueReplacement returns the replacement of an empty region value and whether
+// the region must be left unchanged. A `nil` replacement means that the region is handled as usual.
+func (rd *Redel) emptyValueReplacement(value []byte) (replacement []byte, skip bool) {
+	if !rd.isEmptyValue(value) {
+		return nil, false
+	}
+
+	if rd.skipEmpty {
+		return nil, true
+	}
+
+	return rd.emptyRepl, false
+}
+
+// SetObserver sets an observer which is notified while scanning. A `nil` observer (default) disables it.
+func (rd *Redel) SetObserver(observer Observer) {
+	rd.observer = observer
+}
+
+// BytesRead returns the number of input bytes consumed by the last run, which is available once it returns.
+// Runs via `ReplaceFrom` count the bytes from the seek offset.
+func (rd *Redel) BytesRead() int64 {
+	return rd.bytesRead
+}
+
+// SetTimeout sets the maximum duration of every run, which is aborted with `ErrTimeout` once exceeded.
+// The deadline is checked between tokens, so a blocked read is not interrupted. A zero duration (default) disables it.
+func (rd *Redel) SetTimeout(d time.Duration) {
+	rd.timeout = d
+}
+
+// SetLogger sets a logger which is called when a region is matched, skipped or replaced.
+// A `nil` logger (default) disables logging.
+func (rd *Redel) SetLogger(logger Logger) {
+	rd.logger = logger
+}
+
+// logf logs a message if a logger is set.
+func (rd *Redel) logf(format string, args ...any) {
+	if rd.logger != nil {
+		rd.logger.Logf(format, args...)
+	}
+}
+
+// SetNesting sets how start values inside a region of the same delimiter are handled.
+// The balanced mode is not applied to greedy delimiters, delimiters with alternative, empty or `EOL` end values
+// or delimiters whose start and end values are equal.
+// Note that in `NestingBalanced` mode an outer start value which is not closed yet keeps the data buffered
+// until it's closed or until the end of the stream.
+func (rd *Redel) SetNesting(mode Nesting) {
+	rd.nesting = mode
+}
+
+// isBalanced checks if the regions of a delimiter are matched in balanced mode.
+func (rd *Redel) isBalanced(del Delimiter) bool {
+	return rd.nesting == NestingBalanced && !del.Greedy && len(del.AltEnds) == 0 &&
+		len(del.End) > 0 && !isEOL(del.End) && !bytes.Equal(del.Start, del.End)
+}
+
+// SetTieBreak sets which region wins when regions of several delimiters start at the same position.
+// Note that in `TieBreakLongest` and `TieBreakDeclared` modes a winning start delimiter which is not closed yet
+// keeps the data buffered until it's closed or until the end of the stream.
+func (rd *Redel) SetTieBreak(mode TieBreak) {
+	rd.tieBreak = mode
+}
+
+// precedes checks if the region `a` wins over the region `b` according to the tie-break mode.
+func (rd *Redel) precedes(a earlyDelimiter, b earlyDelimiter) bool {
+	if rd.tieBreak == TieBreakValueStart {
+		return a.startIndex < b.startIndex
+	}
+
+	if a.fromIndex != b.fromIndex {
+		return a.fromIndex < b.fromIndex
+	}
+
+	startLenA := a.startIndex - a.fromIndex
+	startLenB := b.startIndex - b.fromIndex
+
+	if rd.tieBreak == TieBreakLongest && startLenA != startLenB {
+		return startLenA > startLenB
+	}
+
+	return a.delIndex < b.delIndex
+}
+
+// SetMaxInputBytes sets the maximum number of bytes read from the reader. Once exceeded, the scanning stops
+// after processing the first `n` bytes and `ErrInputTooLarge` is returned by functions returning errors.
+// The limit applies to the raw input, not to the replaced output. A value lower or equal to zero (default) means no limit.
+// It has no effect on instances created via `NewFromScanner`.
+func (rd *Redel) SetMaxInputBytes(n int64) {
+	rd.maxInput = n
+}
+
+// Read reads from the underlying reader until reaching the maximum number of bytes.
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.remaining <= 0 {
+		// Check whether there is more data than allowed
+		var b [1]byte
+
+		n, err := lr.reader.Read(b[:])
+
+		if n > 0 {
+			return 0, ErrInputTooLarge
+		}
+
+		return 0, err
+	}
+
+	if int64(len(p)) > lr.remaining {
+		p = p[0:lr.remaining]
+	}
+
+	n, err := lr.reader.Read(p)
+	lr.remaining -= int64(n)
+
+	return n, err
+}
+
+// SetLineEnding sets how line endings of the text passed through by replace functions are normalized.
+// Matched regions, including the ones emitted verbatim, are never changed.
+func (rd *Redel) SetLineEnding(mode LineEnding) {
+	rd.lineEnd = mode
+}
+
+// newLineEndingFunc returns a function which normalizes the line endings of the token text before its region.
+// It returns the token as it is if line endings are kept.
+func (rd *Redel) newLineEndingFunc() func(token scanToken) scanToken {
+	// A carriage return held until knowing if it's followed by a line feed
+	pendingCR := false
+	// Whether the previous byte is a carriage return
+	prevCR := false
+
+	return func(token scanToken) scanToken {
+		if rd.lineEnd == LineEndingKeep {
+			return token
+		}
+
+		end := len(token.data)
+
+		if token.region != nil {
+			end = token.region.fromIndex
+		}
+
+		text := make([]byte, 0, end+end/8)
+
+		for _, b := range token.data[0:end] {
+			switch rd.lineEnd {
+			case LineEndingLF:
+				if pendingCR {
+					pendingCR = false
+
+					if b != '\n' {
+						text = append(text, '\r')
+					}
+				}
+
+				if b == '\r' {
+					pendingCR = true
+					continue
+				}
+			case LineEndingCRLF:
+				if b == '\n' && !prevCR {
+					text = append(text, '\r')
+				}
+
+				prevCR = b == '\r'
+			}
+
+			text = append(text, b)
+		}
+
+		// A carriage return followed by a region or by the end of the stream is kept
+		if pendingCR && (token.region != nil || token.atEOF) {
+			pendingCR = false
+			text = append(text, '\r')
+		}
+
+		if token.region != nil {
+			prevCR = token.data[token.region.toIndex-1] == '\r'
+		}
+
+		return token.withText(text)
+	}
+}
+
+// SetTextFunc sets a function which rewrites the text passed through between regions before emitting it.
+// It's not applied to matched values nor replacements. A `nil` function (default) passes the text unchanged.
+// Note that the text can be passed in several chunks, so a chunk boundary can split a word or a multi-byte character.
+func (rd *Redel) SetTextFunc(textFunc TextReplaceFunc) {
+	rd.textFunc = textFunc
+}
+
+// SetValueTransform sets a function which transforms every matched value (E.g. trimming or lowercasing it)
+// before the filter and replacement decision of every replace function. The transformed value is what filters see
+// and what is emitted when a region keeps its value or it's skipped by a filter. Regions out of the replace or
+// active ranges are not transformed. A `nil` function (default) keeps values unchanged.
+func (rd *Redel) SetValueTransform(transform ValueTransformFunc) {
+	rd.transform = transform
+}
+
+// transformValue returns the token with its region value transformed by the value transform function, if any.
+func (rd *Redel) transformValue(token scanToken) scanToken {
+	if rd.transform == nil || token.region == nil {
+		return token
+	}
+
+	return token.withValue(rd.transform(bytes.Clone(token.region.value)))
+}
+
+// replaceText returns the token with its text before the region (or its whole data without region)
+// rewritten by the text function, if any.
+func (rd *Redel) replaceText(token scanToken) scanToken {
+	if rd.textFunc == nil {
+		return token
+	}
+
+	text := token.data
+
+	if token.region != nil {
+		text = token.data[0:token.region.fromIndex]
+	}
+
+	return token.withText(rd.textFunc(bytes.Clone(text)))
+}
+
+// SetFilterSeesDelimiters sets whether the values passed to filter functions include their start and end delimiters.
+// The value returned by filter functions still replaces only the value between delimiters,
+// so delimiters are emitted according to the preserve option.
+func (rd *Redel) SetFilterSeesDelimiters(seesDelimiters bool) {
+	rd.filterDels = seesDelimiters
+}
+
+// filterInput returns the value of a token region passed to user filter functions.
+func (rd *Redel) filterInput(token scanToken) []byte {
+	region := token.region
+
+	if !rd.filterDels {
+		return region.value
+	}
+
+	return token.data[region.fromIndex:region.toIndex:region.toIndex]
+}
+
+// SetEnsureTrailingNewline sets how the line endings at the end of the replaced data are handled.
+// The added line ending is `\n` unless `LineEndingCRLF` is set, so empty data becomes a single line ending
+// with `TrailingNewlineAlways`. Line endings are held until knowing if they are at the end of the data,
+// so map and text functions could receive them later.
+func (rd *Redel) SetEnsureTrailingNewline(mode TrailingNewline) {
+	rd.trailing = mode
+}
+
+// trailingNewlineMapFunc wraps a map function in order to handle the line endings at the end of the data.
+// The returned flush function calls the map function with the held line endings, if any,
+// since they are not at the end of the data. Nothing is flushed once the map function returned `false`.
+func (rd *Redel) trailingNewlineMapFunc(mapFunc ReplacementMapUntilFunc) (ReplacementMapUntilFunc, func() bool) {
+	if rd.trailing == TrailingNewlineAsInput {
+		return mapFunc, func() bool { return true }
+	}
+
+	var held []byte
+	halted := false
+
+	flush := func() bool {
+		if halted || len(held) == 0 {
+			return !halted
+		}
+
+		out := held
+		held = nil
+		halted = !mapFunc(out, false)
+
+		return !halted
+	}
+
+	trailing := func(data []byte, atEOF bool) bool {
+		if len(held) > 0 {
+			data = append(held, data...)
+			held = nil
+		}
+
+		end := len(bytes.TrimRight(data, "\r\n"))
+
+		if atEOF {
+			data = data[0:end]
+
+			if rd.trailing == TrailingNewlineAlways {
+				if rd.lineEnd == LineEndingCRLF {
+					data = append(data, '\r')
+				}
+
+				data = append(data, '\n')
+			}
+
+			halted = !mapFunc(data, true)
+			return !halted
+		}
+
+		// Line endings are held until knowing if more data follows
+		held = append(held, data[end:]...)
+
+		if end == 0 {
+			return true
+		}
+
+		halted = !mapFunc(data[0:end], false)
+
+		return !halted
+	}
+
+	return trailing, flush
+}
+
+// newScanner returns the scanner used to read the data.
+// It returns `ErrScannerUsed` if the scanner given via `NewFromScanner` was already used.
+func (rd *Redel) newScanner() (*bufio.Scanner, error) {
+	if rd.scanner != nil {
+		if rd.scanner.used {
+			return nil, ErrScannerUsed
+		}
+
+		rd.scanner.used = true
+
+		return rd.scanner.scanner, nil
+	}
+
+	reader := rd.Reader
+
+	if rd.maxInput > 0 {
+		reader = &limitedReader{reader: reader, remaining: rd.maxInput}
+	}
+
+	scanner := bufio.NewScanner(reader)
+
+	if rd.bufferSize > 0 {
+		scanner.Buffer(make([]byte, 0, rd.bufferSize), rd.bufferMax)
+	}
+
+	return scanner, nil
+}
+
+// mapFuncUntil adapts a map function in order to never halt the scanning.
+func mapFuncUntil(mapFunc ReplacementMapFunc) ReplacementMapUntilFunc {
+	return func(data []byte, atEOF bool) bool {
+		mapFunc(data, atEOF)
+		return true
+	}
+}
+
+// filterValue is the filter function which replaces every matched region without a filter value.
+func filterValue(match *regionMatch) ([]byte, error) {
+	return nil, nil
+}
+
+// isWordByte checks if a byte is a word character ([A-Za-z0-9_]).
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// indexDelimiter returns the index of the first delimiter occurrence in data starting at an offset.
+// When word boundaries are required, a delimiter edge which is a word character must not be next to another
+// word character. The `pending` result is `true` when the boundary of the found occurrence can not be checked
+// until reading more data. The `prevByte` value is the byte before data or -1 at the beginning of the stream.
+func indexDelimiter(data []byte, offset int, delimiter []byte, wordBoundary bool, prevByte int, atEOF bool) (index int, pending bool) {
+	for {
+		index = bytes.Index(data[offset:], delimiter)
+
+		if index < 0 {
+			return -1, false
+		}
+
+		index += offset
+
+		if !wordBoundary {
+			return index, false
+		}
+
+		end := index + len(delimiter)
+
+		if end == len(data) && !atEOF && isWordByte(delimiter[len(delimiter)-1]) {
+			return index, true
+		}
+
+		before := prevByte
+
+		if index > 0 {
+			before = int(data[index-1])
+		}
+
+		startOk := !isWordByte(delimiter[0]) || before < 0 || !isWordByte(byte(before))
+		endOk := !isWordByte(delimiter[len(delimiter)-1]) || end == len(data) || !isWordByte(data[end])
+
+		if startOk && endOk {
+			return index, false
+		}
+
+		offset = index + 1
+	}
+}
+
+// lastIndexDelimiter returns the index of the last delimiter occurrence in data starting at an offset.
+// The last occurrence is only known at the end of the stream, so `pending` is `true` until then.
+func lastIndexDelimiter(data []byte, offset int, delimiter []byte, wordBoundary bool, prevByte int, atEOF bool) (index int, pending bool) {
+	if !atEOF {
+		return -1, true
+	}
+
+	if !wordBoundary {
+		index = bytes.LastIndex(data[offset:], delimiter)
+
+		if index < 0 {
+			return -1, false
+		}
+
+		return index + offset, false
+	}
+
+	index = -1
+
+	for {
+		i, _ := indexDelimiter(data, offset, delimiter, wordBoundary, prevByte, atEOF)
+
+		if i < 0 {
+			return index, false
+		}
+
+		index = i
+		offset = i + 1
+	}
+}
+
+// indexBalancedEnd returns the index of the end value which balances a start value located right before the offset,
+// skipping nested start and end values. If the end value could be partially read or nested regions
+// are not closed yet then it returns a pending value.
+func indexBalancedEnd(data []byte, offset int, del Delimiter, prevByte int, atEOF bool) (index int, pending bool) {
+	depth := 1
+
+	for {
+		to, pending := indexDelimiter(data, offset, del.End, del.WordBoundary, prevByte, atEOF)
+
+		if to < 0 || pending {
+			return to, pending
+		}
+
+		from, _ := indexDelimiter(data[0:to], offset, del.Start, del.WordBoundary, prevByte, true)
+
+		// a nested start value before the end value opens a deeper region
+		if from >= 0 {
+			depth++
+			offset = from + len(del.Start)
+			continue
+		}
+
+		depth--
+
+		if depth == 0 {
+			return to, false
+		}
+
+		offset = to + len(del.End)
+	}
+}
+
+// indexEnds returns the index and the length of the nearest end value of a delimiter (`End` or one of `AltEnds`)
+// in data starting at an offset. At the same index the longest end value wins. The `pending` result is `true`
+// when an end value partially read could be nearer than the found one.
+func indexEnds(data []byte, offset int, del Delimiter, prevByte int, atEOF bool) (index int, length int, pending bool) {
+	index = -1
+
+	for i := -1; i < len(del.AltEnds); i++ {
+		end := del.End
+
+		if i >= 0 {
+			end = del.AltEnds[i]
+		}
+
+		if len(end) == 0 {
+			continue
+		}
+
+		to, toPending := indexDelimiter(data, offset, end, del.WordBoundary, prevByte, atEOF)
+
+		if to >= 0 && (index < 0 || to < index || (to == index && len(end) > length)) {
+			index, length, pending = to, len(end), toPending
+		}
+	}
+
+	if index < 0 || pending || atEOF {
+		return index, length, pending
+	}
+
+	// An end value at the end of the data could be nearer once it's fully read
+	for i := -1; i < len(del.AltEnds); i++ {
+		end := del.End
+
+		if i >= 0 {
+			end = del.AltEnds[i]
+		}
+
+		for k := max(offset, len(data)-len(end)+1); k < len(data) && k <= index; k++ {
+			if bytes.HasPrefix(end, data[k:]) {
+				return index, length, true
+			}
+		}
+	}
+
+	return index, length, false
+}
+
+// SplitFunc returns a split function which splits the data by the Redel delimiters in order to be used
+// by a custom bufio.Scanner. Every token contains the text before a region and the region itself
+// (start delimiter, value and end delimiter) or only text when no region is found, so the whole data is kept.
+// Consumers are responsible for detecting and replacing the regions of the tokens themselves.
+// Note that the returned split function keeps state between calls, so it must be used by a single scanner.
+func (rd *Redel) SplitFunc() bufio.SplitFunc {
+	var region *earlyDelimiter
+	var waiting int
+	var unclosed []int
+
+	return rd.newSplitFunc(nil, &region, &waiting, &unclosed)
+}
+
+// appendUnclosed appends the indexes of the found start delimiters before an index which are not appended yet.
+func appendUnclosed(unclosed []int, found []earlyDelimiter, index int) []int {
+	for _, del := range found {
+		if del.fromIndex >= index {
+			continue
+		}
+
+		appended := false
+
+		for _, delIndex := range unclosed {
+			if delIndex == del.delIndex {
+				appended = true
+				break
+			}
+		}
+
+		if !appended {
+			unclosed = append(unclosed, del.delIndex)
+		}
+	}
+
+	return unclosed
+}
+
+// newSplitFunc returns the split function used to scan the data. It sets the matched region of the current token,
+// `nil` for a token containing only text, and appends an EOF token to the last token.
+// It also sets the index of the delimiter whose region is waiting for more data, -1 if there is none,
+// and the indexes of the delimiters whose start values are emitted as text in the current token since they're unclosed.
+func (rd *Redel) newSplitFunc(eof []byte, region **earlyDelimiter, waiting *int, unclosed *[]int) bufio.SplitFunc {
+	delimiters := rd.Delimiters
+
+	// Last consumed byte, -1 at the beginning of the stream
+	prevByte := -1
+
+	// Number of consumed bytes used to check start anchors
+	var consumed int64
+
+	// Whether the end of the stream is emitted as a separate token, since the last region filled the buffer
+	pendingEOF := false
+
+	// Reused between split calls in order to avoid allocations
+	var currentRegion earlyDelimiter
+	foundDelimiters := make([]earlyDelimiter, 0, len(delimiters))
+	foundOpenDelimiters := make([]earlyDelimiter, 0, len(delimiters))
+	foundSkippedDelimiters := make([]earlyDelimiter, 0, len(delimiters))
+
+	// Data size from which the scanner can't buffer more data.
+	// The maximum buffer size of a given scanner is unknown, so its buffer is never considered full
+	maxData := bufio.MaxScanTokenSize
+
+	if rd.scanner != nil {
+		maxData = math.MaxInt
+	} else if rd.bufferSize > 0 {
+		maxData = rd.bufferMax
+	}
+
+	ScanByDelimiters := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		earlyDelimiters := foundDelimiters[:0]
+		openDelimiters := foundOpenDelimiters[:0]
+		skippedDelimiters := foundSkippedDelimiters[:0]
+		var closerDelimiter earlyDelimiter
+
+		*unclosed = (*unclosed)[:0]
+
+		if atEOF && len(data) == 0 {
+			if pendingEOF {
+				pendingEOF = false
+				*region = nil
+				return 0, eof[0:len(eof):len(eof)], nil
+			}
+
+			return 0, nil, nil
+		}
+
+		pendingEOF = false
+
+		full := !atEOF && len(data) >= maxData
+
+		// A full buffer gives up the open start delimiters on demand, otherwise the scanning stops
+		giveUp := full && rd.oversized
+
+		// Index of the first start delimiter whose end delimiter was not found yet
+		openIndex := -1
+		*waiting = -1
+		maxStartLen := 0
+
+		// iterate array of delimiters
+		for delIndex, del := range delimiters {
+			startLen := len(del.Start)
+			endLen := len(del.End)
+
+			// an empty end value matches the end of the stream only on demand
+			untilEOF := endLen <= 0 && del.UntilEOF
+
+			if startLen <= 0 || (endLen <= 0 && !untilEOF) {
+				continue
+			}
+
+			if startLen > maxStartLen {
+				maxStartLen = startLen
+			}
+
+			// store every found delimiter
+			searchIndex := 0
+
+			for {
+				from, pending := indexDelimiter(data, searchIndex, del.Start, del.WordBoundary, prevByte, atEOF)
+
+				if from < 0 {
+					break
+				}
+
+				// an anchored start value only matches at the beginning of the stream
+				if del.AnchorStart && (consumed > 0 || from > 0) {
+					break
+				}
+
+				// the end delimiter is searched right after the start delimiter
+				x1 := from + startLen
+				to := -1
+
+				if !pending && !untilEOF {
+					if del.Greedy {
+						to, pending = lastIndexDelimiter(data, x1, del.End, del.WordBoundary, prevByte, atEOF)
+					} else if len(del.AltEnds) > 0 {
+						to, endLen, pending = indexEnds(data, x1, del, prevByte, atEOF)
+					} else if rd.isBalanced(del) {
+						to, pending = indexBalancedEnd(data, x1, del, prevByte, atEOF)
+					} else {
+						to, pending = indexDelimiter(data, x1, del.End, del.WordBoundary, prevByte, atEOF)
+					}
+				}
+
+				isLineEnd := isEOL(del.End)
+
+				// the end of the stream closes the last line and the regions until the end of the stream
+				lastLine := to < 0 && (isLineEnd || untilEOF) && atEOF
+
+				// an unclosed outer start value is skipped at the end of the stream, so its nested regions can match
+				if to < 0 && !lastLine && atEOF && rd.isBalanced(del) {
+					skippedDelimiters = append(skippedDelimiters, earlyDelimiter{fromIndex: from, delIndex: delIndex})
+					searchIndex = x1
+					continue
+				}
+
+				if pending || (to < 0 && !lastLine) {
+					if openIndex < 0 || from < openIndex {
+						openIndex = from
+						*waiting = delIndex
+					}
+
+					openDelimiters = append(openDelimiters, earlyDelimiter{
+						startIndex: x1,
+						fromIndex:  from,
+						delIndex:   delIndex,
+					})
+
+					break
+				}
+
+				x2 := to
+				x3 := x2 + endLen
+
+				if lastLine {
+					x2 = len(data)
+					x3 = x2
+				}
+
+				// a carriage return before the line feed is part of the end of line
+				if isLineEnd && x2 > x1 && data[x2-1] == '\r' {
+					x2--
+				}
+
+				// the line ending is not part of the region, so it's emitted regardless of the preserve option
+				if isLineEnd {
+					x3 = x2
+				}
+
+				// an anchored end value only matches at the end of the stream, so a later start value is tried
+				if del.AnchorEnd && (!atEOF || x3 < len(data)) {
+					if !atEOF {
+						if openIndex < 0 || from < openIndex {
+							openIndex = from
+							*waiting = delIndex
+						}
+
+						openDelimiters = append(openDelimiters, earlyDelimiter{
+							startIndex: x1,
+							fromIndex:  from,
+							delIndex:   delIndex,
+						})
+
+						break
+					}
+
+					searchIndex = from + 1
+					continue
+				}
+
+				val := data[x1:x2]
+
+				// values out of bounds are not considered matches, so search after them
+				if !rd.isValueLengthInBounds(len(val)) {
+					searchIndex = x3
+					continue
+				}
+
+				earlyDelimiters = append(earlyDelimiters, earlyDelimiter{
+					value:      val,
+					delimiter:  del,
+					startIndex: x1,
+					endIndex:   x2,
+					fromIndex:  from,
+					toIndex:    x3,
+					delIndex:   delIndex,
+				})
+
+				break
+			}
+		}
+
+		if len(earlyDelimiters) > 0 {
+			// Determine the closer delimiter. Its whole region is consumed,
+			// so delimiters of other overlapping regions inside it are not considered anymore
+			for i, del := range earlyDelimiters {
+				if i == 0 || rd.precedes(del, closerDelimiter) {
+					closerDelimiter = del
+				}
+			}
+
+			// A start delimiter at the same position could win once it's closed, so request more data
+			// unless the open start delimiter is given up
+			if !atEOF && !giveUp && rd.tieBreak != TieBreakValueStart {
+				for _, open := range openDelimiters {
+					if open.fromIndex == closerDelimiter.fromIndex && rd.precedes(open, closerDelimiter) {
+						*waiting = open.delIndex
+						return 0, nil, nil
+					}
+				}
+			}
+
+			// A previous start delimiter could be closed by data not read yet,
+			// so emit only the text before it and request more data
+			if !atEOF && openIndex >= 0 && openIndex < closerDelimiter.fromIndex {
+				if openIndex > 0 {
+					*region = nil
+					return openIndex, data[0:openIndex], nil
+				}
+
+				// The open start delimiter is given up and its first byte emitted as text
+				if giveUp {
+					*region = nil
+					*unclosed = appendUnclosed(*unclosed, openDelimiters, 1)
+					return 1, data[0:1], nil
+				}
+
+				return 0, nil, nil
+			}
+
+			// Start delimiters left open at the end of the stream are emitted as text before the region
+			if atEOF {
+				*unclosed = appendUnclosed(*unclosed, openDelimiters, closerDelimiter.fromIndex)
+				*unclosed = appendUnclosed(*unclosed, skippedDelimiters, closerDelimiter.fromIndex)
+			}
+
+			// The token contains the text before the region and the region itself
+			currentRegion = closerDelimiter
+			*waiting = closerDelimiter.delIndex
+			*region = &currentRegion
+			advance = closerDelimiter.toIndex
+
+			// Request more data to know the context bytes after the region
+			if rd.ctxWindow > 0 {
+				if !atEOF && !full && advance+rd.ctxWindow > len(data) {
+					return 0, nil, nil
+				}
+
+				currentRegion.after = data[advance:min(advance+rd.ctxWindow, len(data))]
+			}
+
+			// Request more data to know whether the region is the last token
+			// unless the buffer is full, then the end of the stream is emitted later as a separate token
+			if advance == len(data) {
+				if !atEOF {
+					if full {
+						pendingEOF = true
+						return advance, data[0:advance], nil
+					}
+
+					return 0, nil, nil
+				}
+
+				last := append(data[0:], eof...)
+				return advance, last, nil
+			}
+
+			return advance, data[0:advance], nil
+		}
+
+		*region = nil
+
+		if atEOF && len(data) > 0 {
+			*unclosed = appendUnclosed(*unclosed, openDelimiters, len(data))
+			*unclosed = appendUnclosed(*unclosed, skippedDelimiters, len(data))
+
+			last := append(data[0:], eof...)
+			return len(data), last, nil
+		}
+
+		// Emit the text which can not be part of a region (a start delimiter could be partially read)
+		// but keep some data in order to know which is the last token
+		safeIndex := len(data) - maxStartLen
+
+		if maxStartLen == 0 {
+			safeIndex = len(data) - 1
+		}
+
+		if openIndex >= 0 && openIndex < safeIndex {
+			safeIndex = openIndex
+		}
+
+		// The open start delimiter is given up and its first byte emitted as text
+		if giveUp && safeIndex <= 0 {
+			safeIndex = 1
+			*unclosed = appendUnclosed(*unclosed, openDelimiters, 1)
+		}
+
+		if safeIndex > 0 {
+			return safeIndex, data[0:safeIndex], nil
+		}
+
+		return 0, nil, nil
+	}
+
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = ScanByDelimiters(data, atEOF)
+
+		// Keep the last consumed byte in order to check word boundaries
+		if advance > 0 {
+			prevByte = int(data[advance-1])
+			consumed += int64(advance)
+		}
+
+		return advance, token, err
+	}
+}
+
+// scanTokens scans the data calling a token function for every scanned token until it returns `false`.
+// Note that the token data is only valid until the token function returns.
+// It returns the first non-EOF error found by the scanner.
+func (rd *Redel) scanTokens(tokenFunc func(token scanToken) bool) error {
+	scanner, err := rd.newScanner()
+
+	if err != nil {
+		return err
+	}
+
+	// Matched region of the current token, `nil` for a token containing only text
+	var region *earlyDelimiter
+
+	// Index of the delimiter whose region is waiting for more data
+	waiting := -1
+
+	// Indexes of the delimiters whose start values are emitted unclosed in the current token
+	var unclosed []int
+
+	scanner.Split(rd.newSplitFunc(rd.eof, &region, &waiting, &unclosed))
+
+	// Input offsets are absolute, so they start at the seek offset of `ReplaceFrom`
+	offset := rd.baseOffset
+	pos := newPosition(rd.byteCols)
+	rd.bytesRead = 0
+
+	// Last consumed bytes used as context before the regions
+	var history []byte
+
+	var deadline time.Time
+
+	if rd.timeout > 0 {
+		deadline = time.Now().Add(rd.timeout)
+	}
+
+	// Scan every token based on current split function
+	for scanner.Scan() {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return ErrTimeout
+		}
+
+		data := scanner.Bytes()
+
+		atEOF := bytes.HasSuffix(data, rd.eof)
+
+		if atEOF {
+			data = data[0 : len(data)-len(rd.eof)]
+		}
+
+		if rd.observer != nil {
+			rd.observer.OnBytes(len(data))
+
+			if region != nil {
+				rd.observer.OnMatch(region.delimiter, bytes.Clone(region.value))
+			}
+		}
+
+		if region != nil {
+			rd.logf("redel: match %q at offset %d", region.value, offset+int64(region.fromIndex))
+		}
+
+		if rd.ctxWindow > 0 && region != nil {
+			region.before = lastBytes(append(history, data[0:region.fromIndex]...), rd.ctxWindow)
+		}
+
+		token := scanToken{data: data, region: region, atEOF: atEOF, offset: offset, unclosed: unclosed}
+
+		if rd.positions {
+			tokenPos := pos
+
+			if region != nil {
+				tokenPos.advance(data[0:region.fromIndex])
+			}
+
+			token.line = tokenPos.line
+			token.column = tokenPos.column
+			pos.advance(data)
+		}
+
+		rd.bytesRead = offset - rd.baseOffset + int64(len(data))
+
+		if !tokenFunc(token) {
+			return nil
+		}
+
+		if rd.ctxWindow > 0 {
+			history = append(history[:0:0], lastBytes(append(history, data...), rd.ctxWindow)...)
+		}
+
+		offset += int64(len(data))
+	}
+
+	err = scanner.Err()
+
+	if err == bufio.ErrTooLong {
+		if waiting >= 0 {
+			return fmt.Errorf("%w: start delimiter %q: %w", ErrRegionTooLong, rd.Delimiters[waiting].Start, err)
+		}
+
+		return fmt.Errorf("%w: %w", ErrRegionTooLong, err)
+	}
+
+	return err
+}
+
+// lastBytes returns the last `n` bytes of data or the whole data if it's shorter.
+func lastBytes(data []byte, n int) []byte {
+	if len(data) > n {
+		return data[len(data)-n:]
+	}
+
+	return data
+}
+
+// newPosition returns a position at the beginning of the stream.
+func newPosition(byteColumns bool) position {
+	return position{line: 1, column: 1, byteColumns: byteColumns}
+}
+
+// advance moves the position after the data. Columns count UTF-8 runes unless `byteColumns` is `true`.
+func (pos *position) advance(data []byte) {
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		pos.line += bytes.Count(data, []byte("\n"))
+		pos.column = 1
+		data = data[i+1:]
+	}
+
+	if pos.byteColumns {
+		pos.column += len(data)
+		return
+	}
+
+	// Count rune starts only since a rune can be split between tokens
+	for _, b := range data {
+		if b&0xC0 != 0x80 {
+			pos.column++
+		}
+	}
+}
+
+// clone returns a copy of the token which doesn't share memory with the scanner.
+func (token scanToken) clone() scanToken {
+	data := make([]byte, len(token.data))
+	copy(data, token.data)
+
+	clone := token
+	clone.data = data
+	clone.unclosed = append([]int(nil), token.unclosed...)
+
+	if token.region != nil {
+		region := *token.region
+		region.value = data[region.startIndex:region.endIndex:region.endIndex]
+		region.before = bytes.Clone(region.before)
+		region.after = bytes.Clone(region.after)
+		clone.region = &region
+	}
+
+	return clone
+}
+
+// tokenData returns the token data to be passed to map functions. It's copied only once, so data
+// without regions is passed through with a single copy. Normalized line endings are already a copy.
+func (rd *Redel) tokenData(token scanToken) []byte {
+	if rd.lineEnd != LineEndingKeep {
+		return token.data
+	}
+
+	return bytes.Clone(token.data)
+}
+
+// withText returns a copy of the token with its text before the region replaced.
+func (token scanToken) withText(text []byte) scanToken {
+	if token.region == nil {
+		token.data = text
+		return token
+	}
+
+	region := *token.region
+	delta := len(text) - region.fromIndex
+	data := append(text, token.data[region.fromIndex:]...)
+
+	region.fromIndex += delta
+	region.startIndex += delta
+	region.endIndex += delta
+	region.toIndex += delta
+	region.value = data[region.startIndex:region.endIndex:region.endIndex]
+
+	token.data = data
+	token.region = &region
+
+	return token
+}
+
+// withValue returns a copy of the token with its region value replaced.
+func (token scanToken) withValue(value []byte) scanToken {
+	region := *token.region
+	delta := len(value) - len(region.value)
+
+	data := make([]byte, 0, len(token.data)+delta)
+	data = append(data, token.data[0:region.startIndex]...)
+	data = append(data, value...)
+	data = append(data, token.data[region.endIndex:]...)
+
+	region.endIndex += delta
+	region.toIndex += delta
+	region.value = data[region.startIndex:region.endIndex:region.endIndex]
+
+	token.data = data
+	token.region = &region
+
+	return token
+}
+
+// replacementValue returns the value which replaces a region value. The region keeps its value when it's
+// not replaced, otherwise it takes the filter value (`replaceWith`) or the replacement value,
+// so an empty filter or replacement value is a legit replacement.
+func replacementValue(valueCurrent []byte, valueToReplace []byte, replace bool, replaceWith bool, replacement []byte) []byte {
+	if !replace {
+		return valueCurrent
+	}
+
+	if replaceWith {
+		return valueToReplace
+	}
+
+	return replacement
+}
+
+// replace returns the token bytes with its region value replaced.
+func (token scanToken) replace(value []byte, preserve Preserve) []byte {
+	region := token.region
+
+	bytesR := make([]byte, 0, len(token.data))
+	bytesR = append(bytesR, token.data[0:region.fromIndex]...)
+
+	// Keep delimiters only if they should be preserved
+	if preserve&PreserveStart != 0 {
+		bytesR = append(bytesR, token.data[region.fromIndex:region.startIndex]...)
+	}
+
+	bytesR = append(bytesR, value...)
+
+	if preserve&PreserveEnd != 0 {
+		bytesR = append(bytesR, token.data[region.endIndex:region.toIndex]...)
+	}
+
+	return bytesR
+}
+
+// coalesceMapFunc wraps a map function in order to call it with data of at least the flush threshold size.
+// The returned flush function calls the map function with the remaining data, if any.
+func (rd *Redel) coalesceMapFunc(mapFunc ReplacementMapUntilFunc) (ReplacementMapUntilFunc, func()) {
+	if rd.flushSize <= 0 {
+		return mapFunc, func() {}
+	}
+
+	var buf []byte
+	halted := false
+
+	coalesced := func(data []byte, atEOF bool) bool {
+		buf = append(buf, data...)
+
+		if !atEOF && len(buf) < rd.flushSize {
+			return true
+		}
+
+		out := buf
+		buf = nil
+		halted = !mapFunc(out, atEOF)
+
+		return !halted
+	}
+
+	flush := func() {
+		if !halted && len(buf) > 0 {
+			out := buf
+			buf = nil
+			mapFunc(out, false)
+		}
+	}
+
+	return coalesced, flush
+}
+
+// isSpaceBytes checks if data contains only whitespace characters (space, \t, \n, \v, \f and \r).
+func isSpaceBytes(data []byte) bool {
+	for _, b := range data {
+		switch b {
+		case ' ', '\t', '\n', '\v', '\f', '\r':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// replaceFilterFunc is the API function which scans and replace bytes supporting different options.
+// It's used by API's replace functions and it returns the first non-EOF error found by the scanner
+// or the first error returned by the filter function.
+func (rd *Redel) replaceFilterFunc(
+	replacementMapFunc ReplacementMapUntilFunc,
+	filterFunc filterMatchFunc,
+	preserve Preserve,
+	replaceWith bool,
+	replacement []byte,
+) error {
+	return rd.replaceFilterFuncParallel(replacementMapFunc, filterFunc, preserve, replaceWith, replacement, 0)
+}
+
+// replaceFilterFuncParallel works like `replaceFilterFunc` but the filter function is called concurrently
+// by the given number of goroutines. A zero concurrency calls it from the calling goroutine.
+func (rd *Redel) replaceFilterFuncParallel(
+	replacementMapFunc ReplacementMapUntilFunc,
+	filterFunc filterMatchFunc,
+	preserve Preserve,
+	replaceWith bool,
+	replacement []byte,
+	concurrency int,
+) error {
+	replacementMapFunc, flushTrailing := rd.trailingNewlineMapFunc(replacementMapFunc)
+	replacementMapFunc, flush := rd.coalesceMapFunc(replacementMapFunc)
+
+	// Held data is flushed when the replacement stops before the end of the data
+	defer func() {
+		flush()
+		flushTrailing()
+	}()
+
+	return rd.replaceTokens(func(token replacedToken) bool {
+		return replacementMapFunc(token.data, token.atEOF)
+	}, filterFunc, preserve, replaceWith, replacement, concurrency)
+}
+
+// replaceTokens scans and replaces every token calling an emit function with the replaced tokens in stream order.
+// It takes every replace decision, so every replace function supports the same options.
+// The filter function is called concurrently by the given number of goroutines, but every other callback
+// is called in stream order from the calling goroutine. A zero concurrency calls it from the calling goroutine too.
+// It returns the first non-EOF error found by the scanner or the first error returned by the filter function.
+func (rd *Redel) replaceTokens(
+	emitFunc func(token replacedToken) bool,
+	filterFunc filterMatchFunc,
+	preserve Preserve,
+	replaceWith bool,
+	replacement []byte,
+	concurrency int,
+) error {
+	// filterResult defines the result of a filter function call
+	type filterResult struct {
+		value []byte
+		err   error
+	}
+
+	// pendingToken defines a token which is finished in stream order once its filter result is available
+	type pendingToken struct {
+		result chan filterResult
+		finish func(result filterResult) bool
+	}
+
+	// Zero-based index of the current matched region in stream order
+	matchIndex := 0
+
+	var errFilter error
+
+	// Variables to control the collapse of adjacent replacements
+	var lastValue []byte
+	hasLastValue := false
+	var spaces []byte
+
+	// emit calls the emit function with the token breaking the adjacency of replacements
+	emit := func(token replacedToken) bool {
+		if len(spaces) > 0 {
+			token.data = append(spaces, token.data...)
+			token.textLen += len(spaces)
+			spaces = nil
+		}
+
+		hasLastValue = false
+
+		return emitFunc(token)
+	}
+
+	// emitText calls the emit function with data passed through unchanged
+	emitText := func(data []byte, atEOF bool) bool {
+		return emit(replacedToken{data: data, textLen: len(data), atEOF: atEOF})
+	}
+
+	// Filter functions are called by a pool of goroutines when running concurrently
+	var jobs chan func()
+
+	if concurrency > 0 {
+		jobs = make(chan func())
+		defer close(jobs)
+
+		for i := 0; i < concurrency; i++ {
+			go func() {
+				for job := range jobs {
+					job()
+				}
+			}()
+		}
+	}
+
+	// Tokens waiting for their filter results in stream order
+	var queue []pendingToken
+
+	// Whether a token stopped the replacement
+	halted := false
+
+	// finishQueue finishes the queued tokens in stream order while their filter results are available
+	// or while more tokens than the given number are queued. It returns false once a token halts.
+	finishQueue := func(maxQueued int) bool {
+		for len(queue) > 0 {
+			pending := queue[0]
+
+			if len(queue) <= maxQueued && pending.result != nil && len(pending.result) == 0 {
+				return true
+			}
+
+			var result filterResult
+			if pending.result != nil {
+				result = <-pending.result
+			}
+
+			queue = queue[1:]
+
+			if !pending.finish(result) {
+				halted = true
+				return false
+			}
+		}
+
+		return true
+	}
+
+	// push finishes a token once the filter result of its match is available.
+	// Tokens without match or running serially are finished right away when nothing is queued.
+	push := func(match *regionMatch, finish func(result filterResult) bool) bool {
+		if jobs == nil {
+			var result filterResult
+			if match != nil {
+				result.value, result.err = filterFunc(match)
+			}
+
+			if !finish(result) {
+				halted = true
+				return false
+			}
+
+			return true
+		}
+
+		pending := pendingToken{finish: finish}
+
+		if match != nil {
+			result := make(chan filterResult, 1)
+			pending.result = result
+
+			jobs <- func() {
+				value, err := filterFunc(match)
+				result <- filterResult{value: value, err: err}
+			}
+		}
+
+		queue = append(queue, pending)
+
+		return finishQueue(concurrency)
+	}
+
+	lineEndingFunc := rd.newLineEndingFunc()
+
+	// Whether any token was scanned, since empty data has no tokens
+	scanned := false
+
+	err := rd.scanTokens(func(token scanToken) bool {
+		scanned = true
+
+		var inputStart, inputEnd int64
+
+		// Input offsets are taken before transforming the token
+		if token.region != nil {
+			inputStart = token.offset + int64(token.region.fromIndex)
+			inputEnd = token.offset + int64(token.region.toIndex)
+		}
+
+		// Queued tokens can't share memory with the scanner
+		if jobs != nil {
+			token = token.clone()
+		}
+
+		token = rd.replaceText(lineEndingFunc(token))
+
+		// Text only tokens are passed through
+		if token.region == nil {
+			// Nothing to emit while a carriage return is held
+			if len(token.data) == 0 && !token.atEOF {
+				return true
+			}
+
+			data := rd.tokenData(token)
+
+			return push(nil, func(filterResult) bool {
+				// Spaces after a replacement are held until knowing if the next replacement collapses
+				if rd.collapse && hasLastValue && !token.atEOF && isSpaceBytes(data) {
+					spaces = append(spaces, data...)
+					return true
+				}
+
+				return emitText(data, token.atEOF)
+			})
+		}
+
+		// Regions out of the replace range are emitted verbatim
+		if !rd.isInReplaceRange(matchIndex, inputStart) {
+			matchIndex++
+			data := token.clone().data
+
+			return push(nil, func(filterResult) bool {
+				return emitText(data, token.atEOF)
+			})
+		}
+
+		token = rd.transformValue(token)
+
+		valueCurrent := make([]byte, len(token.region.value))
+		copy(valueCurrent, token.region.value)
+
+		match := &regionMatch{
+			value:          valueCurrent,
+			input:          bytes.Clone(rd.filterInput(token)),
+			delimiter:      token.region.delimiter,
+			delimiterIndex: token.region.delIndex,
+			index:          matchIndex,
+			before:         bytes.Clone(token.region.before),
+			after:          bytes.Clone(token.region.after),
+			offset:         inputStart,
+			line:           token.line,
+			column:         token.column,
+			preserve:       preserve,
+			replace:        true,
+		}
+
+		matchIndex++
+
+		emptyReplacement, skipEmpty := rd.emptyValueReplacement(valueCurrent)
+
+		// Empty regions are left unchanged or replaced without calling the filter
+		if skipEmpty {
+			data := token.clone().data
+
+			return push(nil, func(filterResult) bool {
+				rd.logf("redel: region %d skipped (empty value)", match.index)
+				return emitText(data, token.atEOF)
+			})
+		}
+
+		filterMatch := match
+		if emptyReplacement != nil {
+			filterMatch = nil
+		}
+
+		return push(filterMatch, func(result filterResult) bool {
+			valueToReplace, err := result.value, result.err
+
+			regionReplaceWith := replaceWith
+
+			if emptyReplacement != nil {
+				valueToReplace = emptyReplacement
+				regionReplaceWith = true
+			}
+
+			if err == ErrSkipRegion {
+				rd.logf("redel: region %d skipped by filter", match.index)
+				return emitText(token.clone().data, token.atEOF)
+			}
+
+			if err != nil {
+				rd.logf("redel: region %d filter error: %v", match.index, err)
+				errFilter = err
+				return false
+			}
+
+			value := replacementValue(valueCurrent, valueToReplace, match.replace, regionReplaceWith, replacement)
+
+			// Deleted regions are removed including their delimiters
+			if isDelete(valueToReplace) {
+				value = []byte{}
+				match.preserve = PreserveNone
+			}
+
+			if match.replace {
+				rd.logf("redel: region %d replaced with %q", match.index, value)
+			} else {
+				rd.logf("redel: region %d kept", match.index)
+			}
+
+			if rd.matchFunc != nil {
+				rd.matchFunc(valueCurrent, value)
+			}
+
+			if rd.observer != nil {
+				rd.observer.OnReplace(valueCurrent, value)
+			}
+
+			// Collapse the replacement into the previous one when they are equal and only spaces are between them
+			if rd.collapse && hasLastValue && bytes.Equal(value, lastValue) &&
+				isSpaceBytes(token.data[0:token.region.fromIndex]) {
+				spaces = nil
+
+				if token.atEOF {
+					return emitFunc(replacedToken{data: []byte{}, atEOF: true})
+				}
+
+				return true
+			}
+
+			ok := emit(replacedToken{
+				data:        token.replace(value, match.preserve),
+				textLen:     token.region.fromIndex,
+				replaced:    true,
+				value:       valueCurrent,
+				replacement: value,
+				inputStart:  inputStart,
+				inputEnd:    inputEnd,
+				atEOF:       token.atEOF,
+			})
+
+			lastValue = value
+			hasLastValue = true
+
+			return ok
+		})
+	})
+
+	// Tokens still queued are finished unless a token stopped the replacement
+	if !halted {
+		finishQueue(0)
+	}
+
+	if errFilter != nil {
+		return errFilter
+	}
+
+	// The line ending of empty data is added to an empty last token
+	if err == nil && !scanned && rd.trailing == TrailingNewlineAlways {
+		emitFunc(replacedToken{data: []byte{}, atEOF: true})
+	}
+
+	return err
+}
+
+// Replace function replaces every occurrence of the reader data with a custom replacement token
+// writing the result to a writer. It returns the number of bytes written and any error encountered.
+// A writer with a `Flush() error` method (E.g. bufio.Writer) is flushed before returning,
+// but the writer is never closed, even if it's an io.Closer.
+func Replace(w io.Writer, r io.Reader, delimiters []Delimiter, replacement []byte) (int64, error) {
+	var written int64
+	var errWrite error
+
+	rd := New(r, delimiters)
+
+	err := rd.replaceFilterFunc(func(data []byte, atEOF bool) bool {
+		n, err := w.Write(data)
+		written += int64(n)
+		errWrite = err
+
+		return err == nil
+	}, filterValue, PreserveNone, false, replacement)
+
+	if errWrite != nil {
+		return written, errWrite
+	}
+
+	if flusher, ok := w.(interface{ Flush() error }); ok {
+		if errFlush := flusher.Flush(); errFlush != nil {
+			return written, errFlush
+		}
+	}
+
+	return written, err
+}
+
+// ReplaceFile replaces every occurrence of a file data with a custom replacement token atomically.
+// The result is written to a temporary file in the same directory which is synced and then renamed over
+// the original file keeping its permissions, so an interrupted run never corrupts the original file.
+// The temporary file is removed on error.
+func ReplaceFile(path string, delimiters []Delimiter, replacement []byte) (err error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".redel-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = Replace(tmp, file, delimiters, replacement); err != nil {
+		return err
+	}
+
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// NewReader returns a reader which replaces every occurrence of the reader data with a custom replacement token
+// lazily as it's read. It's backed by a pipe written by a goroutine which ends once the data is fully read.
+func NewReader(reader io.Reader, delimiters []Delimiter, replacement []byte) io.Reader {
+	return newPipeReader(reader, delimiters, replacement)
+}
+
+// WrapReadCloser returns a read closer which replaces every occurrence of the read closer data with a custom
+// replacement token lazily as it's read, E.g. an `http.Response.Body`. Its `Close` method stops the replacement
+// and closes the underlying read closer.
+func WrapReadCloser(rc io.ReadCloser, delimiters []Delimiter, replacement []byte) io.ReadCloser {
+	return &readCloser{
+		PipeReader: newPipeReader(rc, delimiters, replacement),
+		closer:     rc,
+	}
+}
+
+// Close closes the replaced reader and the underlying read closer.
+func (rc *readCloser) Close() error {
+	rc.PipeReader.Close()
+
+	return rc.closer.Close()
+}
+
+// newPipeReader returns a pipe reader written by a goroutine which replaces every occurrence of the reader data.
+func newPipeReader(reader io.Reader, delimiters []Delimiter, replacement []byte) *io.PipeReader {
+	pr, pw := io.Pipe()
+
+	go func() {
+		_, err := Replace(pw, reader, delimiters, replacement)
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+// NewProcessor creates a new Processor which replaces every occurrence with a custom replacement token.
+// It's backed by a goroutine which ends once `Finish` is called, so the processor must always be finished.
+func NewProcessor(delimiters []Delimiter, replacement []byte) *Processor {
+	proc := &Processor{
+		chunks: make(chan []byte),
+		ready:  make(chan struct{}),
+		done:   make(chan error, 1),
+	}
+
+	rd := New(processorReader{proc: proc}, delimiters)
+
+	go func() {
+		proc.done <- rd.replaceFilterFunc(func(data []byte, atEOF bool) bool {
+			proc.output = append(proc.output, data...)
+			return true
+		}, filterValue, PreserveNone, false, replacement)
+	}()
+
+	// Wait until the first chunk is requested
+	<-proc.ready
+
+	return proc
+}
+
+// Read reads the written chunks. It's called by the processor goroutine only.
+func (pr processorReader) Read(p []byte) (int, error) {
+	proc := pr.proc
+
+	if len(proc.pending) == 0 {
+		// Every written chunk is consumed, so the replaced data is ready
+		proc.ready <- struct{}{}
+
+		chunk, ok := <-proc.chunks
+
+		if !ok {
+			return 0, io.EOF
+		}
+
+		proc.pending = chunk
+	}
+
+	n := copy(p, proc.pending)
+	proc.pending = proc.pending[n:]
+
+	return n, nil
+}
+
+// Write processes a chunk of data returning the replaced data available so far.
+// Data which could be part of a region is kept until next writes or until `Finish` is called.
+func (proc *Processor) Write(data []byte) ([]byte, error) {
+	if proc.finished {
+		return nil, ErrProcessorFinished
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	proc.chunks <- bytes.Clone(data)
+
+	select {
+	case <-proc.ready:
+	case err := <-proc.done:
+		proc.finished = true
+		close(proc.chunks)
+
+		if err == nil {
+			err = ErrProcessorFinished
+		}
+
+		return proc.takeOutput(), err
+	}
+
+	return proc.takeOutput(), nil
+}
+
+// Finish ends the processing returning the remaining replaced data and the first error found, if any.
+func (proc *Processor) Finish() ([]byte, error) {
+	if proc.finished {
+		return nil, ErrProcessorFinished
+	}
+
+	proc.finished = true
+	close(proc.chunks)
+
+	err := <-proc.done
+
+	return proc.takeOutput(), err
+}
+
+// takeOutput returns the replaced data collected so far.
+func (proc *Processor) takeOutput() []byte {
+	output := proc.output
+	proc.output = nil
+
+	return output
+}
+
+// NewWriter returns a writer which replaces every occurrence of the data written to it with a custom replacement token,
+// writing the replaced data to an underlying writer as it's available. Data which could be part of a region is kept
+// between writes until `Close` is called, which must always be done. A writer with a `Flush() error` method
+// (E.g. bufio.Writer) is flushed on `Close`, but the underlying writer is never closed.
+func NewWriter(w io.Writer, delimiters []Delimiter, replacement []byte) io.WriteCloser {
+	return &processorWriter{proc: NewProcessor(delimiters, replacement), w: w}
+}
+
+// Write replaces the data writing the replaced data available so far to the underlying writer.
+func (pw *processorWriter) Write(data []byte) (int, error) {
+	output, err := pw.proc.Write(data)
+
+	if len(output) > 0 {
+		if _, errWrite := pw.w.Write(output); errWrite != nil {
+			return 0, errWrite
+		}
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// Close writes the remaining replaced data to the underlying writer and flushes it if it's supported.
+func (pw *processorWriter) Close() error {
+	output, err := pw.proc.Finish()
+
+	if len(output) > 0 {
+		if _, errWrite := pw.w.Write(output); errWrite != nil {
+			return errWrite
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if flusher, ok := pw.w.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
+// AddStage appends a stage with its own delimiters and replacement applied by `RunStaged`.
+func (rd *Redel) AddStage(delimiters []Delimiter, replacement []byte) {
+	rd.stages = append(rd.stages, stage{delimiters: delimiters, replacement: replacement})
+}
+
+// RunStaged function reads the data once applying every stage in order, where the first stage scans the Redel reader
+// and every next stage replaces the tokens emitted by the previous one. A region of a next stage can span several
+// tokens, so every next stage buffers its input until its regions are complete, like a `Processor` does.
+// A replacement of a stage is matched by the delimiters of the next stages too, E.g. a `[x]` replacement of a first stage
+// is replaced by a second stage with `[` and `]` delimiters. Stages are processed with default options
+// and the Redel delimiters are not used. It returns the first non-EOF error found while reading or the first error
+// found by a stage. Note that it requires the Redel reader, so it returns `ErrNoReader` for instances created via `NewFromScanner`.
+func (rd *Redel) RunStaged(mapFunc ReplacementMapFunc) error {
+	if rd.Reader == nil {
+		return ErrNoReader
+	}
+
+	// The first stage scans the reader, so without stages the data is passed through as it's read
+	var delimiters []Delimiter
+	var replacement []byte
+	var procs []*Processor
+
+	for i, st := range rd.stages {
+		if i == 0 {
+			delimiters, replacement = st.delimiters, st.replacement
+			continue
+		}
+
+		procs = append(procs, NewProcessor(st.delimiters, st.replacement))
+	}
+
+	// process passes the data through the next stages starting from the given one
+	process := func(data []byte, from int) ([]byte, error) {
+		for _, proc := range procs[from:] {
+			var err error
+
+			if data, err = proc.Write(data); err != nil {
+				return nil, err
+			}
+		}
+
+		return data, nil
+	}
+
+	var errProcess error
+
+	first := New(rd.Reader, delimiters)
+	first.SetBufferSize(rd.bufferSize, rd.bufferMax)
+
+	err := first.replaceFilterFunc(func(data []byte, atEOF bool) bool {
+		if data, errProcess = process(data, 0); len(data) > 0 {
+			mapFunc(data, false)
+		}
+
+		return errProcess == nil
+	}, filterValue, PreserveNone, false, replacement)
+
+	if errProcess == nil {
+		errProcess = err
+	}
+
+	// Finish every next stage in order passing its remaining data to the following stages
+	var last []byte
+
+	for i, proc := range procs {
+		data, err := proc.Finish()
+
+		if errProcess != nil {
+			continue
+		}
+
+		if err == nil {
+			data, err = process(data, i+1)
+		}
+
+		if err != nil {
+			errProcess = err
+			continue
+		}
+
+		last = append(last, data...)
+	}
+
+	mapFunc(last, true)
+
+	return errProcess
+}
+
+// Chain connects several passes into a single reader where every pass reads the output of the previous one.
+// Every pass should transform its reader lazily, E.g. via `NewReader`.
+func Chain(reader io.Reader, passes []func(io.Reader) io.Reader) io.Reader {
+	for _, pass := range passes {
+		reader = pass(reader)
+	}
+
+	return reader
+}
+
+// Replace function replaces every occurrence with a custom replacement token.
+func (rd *Redel) Replace(replacement []byte, mapFunc ReplacementMapFunc) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), filterValue, PreserveNone, false, replacement)
+}
+
+// ReplaceAll function replaces every occurrence with a custom replacement token returning the whole result.
+// It returns the first non-EOF error found by the scanner.
+func (rd *Redel) ReplaceAll(replacement []byte) ([]byte, error) {
+	var result []byte
+
+	err := rd.replaceFilterFunc(func(data []byte, atEOF bool) bool {
+		result = append(result, data...)
+		return true
+	}, filterValue, PreserveNone, false, replacement)
+
+	return result, err
+}
+
+// ReplaceTee function replaces every occurrence with a custom replacement token in a single pass
+// writing the replaced data to the `transformed` writer and the data exactly as it's read to the `original` writer.
+// It returns the number of bytes written to the `transformed` writer and any error encountered.
+// Note that it requires the Redel reader, so it returns `ErrNoReader` for instances created via `NewFromScanner`.
+func (rd *Redel) ReplaceTee(transformed io.Writer, original io.Writer, replacement []byte) (int64, error) {
+	if rd.Reader == nil {
+		return 0, ErrNoReader
+	}
+
+	rdTee := *rd
+	rdTee.Reader = io.TeeReader(rd.Reader, original)
+	rdTee.scanner = nil
+
+	var written int64
+	var errWrite error
+
+	err := rdTee.replaceFilterFunc(func(data []byte, atEOF bool) bool {
+		n, err := transformed.Write(data)
+		written += int64(n)
+		errWrite = err
+
+		return err == nil
+	}, filterValue, PreserveNone, false, replacement)
+
+	rd.bytesRead = rdTee.bytesRead
+
+	if errWrite != nil {
+		return written, errWrite
+	}
+
+	return written, err
+}
+
+// ReplaceParts function replaces every occurrence with a custom replacement token like `ReplaceAll` but
+// it calls a text function with the data passed through unchanged and a replacement function with every
+// matched value and its replacement instead. Both functions are called in stream order and the options apply
+// like in `ReplaceAll` (E.g. collapsed replacements are not reported), so the concatenation of the text and
+// the replacements is the whole result. It returns the first non-EOF error found by the scanner.
+func (rd *Redel) ReplaceParts(replacement []byte, textFunc TextFunc, replacementFunc MatchFunc) error {
+	return rd.replaceParts(replacement, func(data []byte) bool {
+		textFunc(data)
+		return true
+	}, func(value []byte, replacement []byte, inputStart int64, inputEnd int64) bool {
+		replacementFunc(value, replacement)
+		return true
+	})
+}
+
+// Events function replaces every occurrence with a custom replacement token emitting every part of the result
+// as an `Event` in stream order. The events channel is closed once the data is fully processed, an error is found
+// or the context is done. Then the error channel delivers the first non-EOF error found by the scanner
+// or the context error, if any, and it's closed. Cancelling the context stops the scanning, so no goroutine
+// is leaked when the consumer stops receiving events.
+func (rd *Redel) Events(ctx context.Context, replacement []byte) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+
+		var errCtx error
+
+		send := func(event Event) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				errCtx = ctx.Err()
+				return false
+			}
+		}
+
+		err := rd.replaceParts(replacement, func(data []byte) bool {
+			return send(Event{Data: data})
+		}, func(value []byte, replacement []byte, inputStart int64, inputEnd int64) bool {
+			return send(Event{Data: bytes.Clone(replacement), Replaced: true, Value: value})
+		})
+
+		close(events)
+
+		if err == nil {
+			err = errCtx
+		}
+
+		if err != nil {
+			errc <- err
+		}
+	}()
+
+	return events, errc
+}
+
+// replaceParts works like `ReplaceParts` but it stops scanning once a callback returns `false`.
+// The replacement function also receives the input offsets of the whole region including its delimiters.
+func (rd *Redel) replaceParts(
+	replacement []byte,
+	textFunc func(data []byte) bool,
+	replacementFunc func(value []byte, replacement []byte, inputStart int64, inputEnd int64) bool,
+) error {
+	textMapFunc, flushText := rd.trailingNewlineMapFunc(func(data []byte, atEOF bool) bool {
+		if len(data) > 0 {
+			return textFunc(data)
+		}
+
+		return true
+	})
+
+	// Held line endings are flushed when the replacement stops before the end of the data
+	defer flushText()
+
+	return rd.replaceTokens(func(token replacedToken) bool {
+		if !token.replaced {
+			return textMapFunc(token.data, token.atEOF)
+		}
+
+		// Line endings held before the replacement are not at the end of the data
+		if !textMapFunc(token.data[0:token.textLen], false) || !flushText() {
+			return false
+		}
+
+		if !replacementFunc(token.value, token.replacement, token.inputStart, token.inputEnd) {
+			return false
+		}
+
+		if token.atEOF {
+			return textMapFunc([]byte{}, true)
+		}
+
+		return true
+	}, filterValue, PreserveNone, false, replacement, 0)
+}
+
+// ReplaceFrom function seeks the reader to an offset from its start and then replaces every occurrence
+// with a custom replacement token. It's useful to resume an interrupted processing. A region split
+// by the offset is not recovered, so its remaining bytes are processed as usual data. Input offsets
+// (E.g. the active range) are absolute, so they count from the start of the reader.
+// It returns `ErrNotSeeker` if the Redel reader is not an io.Seeker, any seek error
+// or the first non-EOF error found by the scanner.
+func (rd *Redel) ReplaceFrom(offset int64, replacement []byte, mapFunc ReplacementMapFunc) error {
+	seeker, ok := rd.Reader.(io.Seeker)
+
+	if !ok || rd.scanner != nil {
+		return ErrNotSeeker
+	}
+
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	rd.baseOffset = offset
+	defer func() { rd.baseOffset = 0 }()
+
+	return rd.replaceFilterFunc(mapFuncUntil(mapFunc), filterValue, PreserveNone, false, replacement)
+}
+
+// ReplaceTemplate function replaces every occurrence with a template replacement where
+// the `{{value}}` placeholder is substituted by the matched value and `{{len}}` by its length.
+func (rd *Redel) ReplaceTemplate(tmpl []byte, mapFunc ReplacementMapFunc) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		result := bytes.ReplaceAll(tmpl, []byte("{{len}}"), []byte(strconv.Itoa(len(match.value))))
+		result = bytes.ReplaceAll(result, []byte("{{value}}"), match.value)
+
+		return result, nil
+	}, PreserveNone, true, []byte(nil))
+}
+
+// ReplaceUntil function replaces every occurrence with a custom replacement token
+// until the map callback returns `false`. No further data is read after that.
+func (rd *Redel) ReplaceUntil(replacement []byte, mapFunc ReplacementMapUntilFunc) {
+	rd.replaceFilterFunc(mapFunc, filterValue, PreserveNone, false, replacement)
+}
+
+// ReplaceFilter function scans and replaces byte occurrences filtering every replacement value via a bool callback.
+// A `true` result inserts the replacement, even if it's empty, and a `false` result reproduces the original value
+// with its delimiters kept according to the preserve option.
+func (rd *Redel) ReplaceFilter(
+	replacement []byte,
+	mapFunc ReplacementMapFunc,
+	filterFunc FilterValueFunc,
+	preserveDelimiters bool,
+) {
+	rd.ReplaceFilterPreserve(replacement, mapFunc, filterFunc, preserveFromBool(preserveDelimiters))
+}
+
+// ReplaceFilterPreserve function scans and replaces byte occurrences filtering every replacement value via a bool callback
+// keeping the delimiters given by the preserve option.
+func (rd *Redel) ReplaceFilterPreserve(
+	replacement []byte,
+	mapFunc ReplacementMapFunc,
+	filterFunc FilterValueFunc,
+	preserve Preserve,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		if filterFunc(match.input) {
+			return replacement, nil
+		}
+
+		// keep the original value so the region is reproduced with the preserved delimiters
+		match.replace = false
+
+		return nil, nil
+	}, preserve, true, []byte(nil))
+}
+
+// ReplaceFilterWithDelimiter function works like `ReplaceFilter` but the bool callback also receives
+// the matched delimiter and the zero-based index of every matched region in stream order.
+func (rd *Redel) ReplaceFilterWithDelimiter(
+	replacement []byte,
+	mapFunc ReplacementMapFunc,
+	filterFunc FilterFunc,
+	preserveDelimiters bool,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		if filterFunc(match.input, match.delimiter, match.index) {
+			return replacement, nil
+		}
+
+		// keep the original value so the region is reproduced with the preserved delimiters
+		match.replace = false
+
+		return nil, nil
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// ReplaceFilterRegexp function scans and replaces only the regions whose value matches a regular expression.
+// Regions whose value doesn't match the pattern are left unchanged including their delimiters.
+func (rd *Redel) ReplaceFilterRegexp(
+	valuePattern *regexp.Regexp,
+	replacement []byte,
+	mapFunc ReplacementMapFunc,
+	preserveDelimiters bool,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		if !valuePattern.Match(match.value) {
+			return nil, ErrSkipRegion
+		}
+
+		return replacement, nil
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// ReplaceFilterWith function scans and replaces byte occurrences via a custom replacement callback.
+func (rd *Redel) ReplaceFilterWith(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplaceFunc,
+	preserveDelimiters bool,
+) {
+	rd.ReplaceFilterWithPreserve(mapFunc, filterReplaceFunc, preserveFromBool(preserveDelimiters))
+}
+
+// ReplaceFilterWithPreserve function scans and replaces byte occurrences via a custom replacement callback
+// keeping the delimiters given by the preserve option.
+func (rd *Redel) ReplaceFilterWithPreserve(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplaceFunc,
+	preserve Preserve,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		return filterReplaceFunc(match.input), nil
+	}, preserve, true, []byte(nil))
+}
+
+// ReplaceFilterWithDynamicPreserve function works like `ReplaceFilterWith` but the callback decides per region
+// whether its delimiters are kept. An empty replacement removes only the value, so kept delimiters remain
+// (E.g. `()`), while `Delete` removes the whole region regardless of the returned preserve value.
+func (rd *Redel) ReplaceFilterWithDynamicPreserve(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplacePreserveFunc,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		replacement, preserve := filterReplaceFunc(match.input)
+		match.preserve = preserveFromBool(preserve)
+
+		return replacement, nil
+	}, PreserveNone, true, []byte(nil))
+}
+
+// ReplaceFilterWithErr function scans and replaces byte occurrences via a custom replacement callback
+// which can abort the whole operation returning an error. The error is returned by this function.
+func (rd *Redel) ReplaceFilterWithErr(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplaceErrFunc,
+	preserveDelimiters bool,
+) error {
+	return rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		return filterReplaceFunc(match.input)
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// ReplaceFilterWithEscaped function scans and replaces byte occurrences via a custom replacement callback
+// escaping every replacement value via an escape function. The rest of the data is not escaped.
+func (rd *Redel) ReplaceFilterWithEscaped(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplaceFunc,
+	escapeFunc EscapeFunc,
+	preserveDelimiters bool,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		return escapeFunc(filterReplaceFunc(match.input)), nil
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// JSONEscape escapes a value in order to be placed inside a JSON string.
+func JSONEscape(value []byte) []byte {
+	const hex = "0123456789abcdef"
+
+	escaped := make([]byte, 0, len(value))
+
+	for _, b := range value {
+		switch b {
+		case '"', '\\':
+			escaped = append(escaped, '\\', b)
+		case '\n':
+			escaped = append(escaped, '\\', 'n')
+		case '\r':
+			escaped = append(escaped, '\\', 'r')
+		case '\t':
+			escaped = append(escaped, '\\', 't')
+		default:
+			if b < 0x20 {
+				escaped = append(escaped, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			} else {
+				escaped = append(escaped, b)
+			}
+		}
+	}
+
+	return escaped
+}
+
+// ReplaceFilterWithParallel function scans and replaces byte occurrences via a custom replacement callback
+// which is called concurrently by a bounded number of goroutines given by the concurrency level.
+// Regions are detected in stream order and the map callback, the observer and the logger are called
+// in that order from the calling goroutine.
+func (rd *Redel) ReplaceFilterWithParallel(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplaceFunc,
+	preserveDelimiters bool,
+	concurrency int,
+) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	rd.replaceFilterFuncParallel(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		return filterReplaceFunc(match.input), nil
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil), concurrency)
+}
+
+// ReplaceFilterWithIndex function scans and replaces byte occurrences via a custom replacement callback
+// which also receives the zero-based index of every matched region in stream order.
+func (rd *Redel) ReplaceFilterWithIndex(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplaceIndexFunc,
+	preserveDelimiters bool,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		return filterReplaceFunc(match.input, match.index), nil
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// ReplaceFilterWithOffset function scans and replaces byte occurrences via a custom replacement callback
+// which also receives the zero-based byte offset of every region value (right after its start delimiter) in the input.
+func (rd *Redel) ReplaceFilterWithOffset(
+	mapFunc ReplacementMapFunc,
+	filterReplaceFunc FilterValueReplaceOffsetFunc,
+	preserveDelimiters bool,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		return filterReplaceFunc(match.input, match.offset+int64(len(match.delimiter.Start))), nil
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// ReplaceFilterWithContext function scans and replaces byte occurrences via a custom replacement callback
+// which also receives the context bytes before the start delimiter and after the end delimiter of every region.
+// The number of context bytes is set via `SetContextWindow`, otherwise context values are empty.
+func (rd *Redel) ReplaceFilterWithContext(
+	mapFunc ReplacementMapFunc,
+	filterFunc FilterWithContextFunc,
+	preserveDelimiters bool,
+) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		return filterFunc(match.input, match.before, match.after), nil
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// ApplyRules function replaces every occurrence of the rule delimiters in a single pass
+// honoring the replacement and the preserve setting of every rule. The Redel delimiters are not used.
+// When start delimiters of several rules overlap, the region which starts first wins and regions starting
+// at the same position are resolved by the tie-break option, so by default the longest start delimiter wins.
+func (rd *Redel) ApplyRules(rules []Rule, mapFunc ReplacementMapFunc) {
+	delimiters := make([]Delimiter, len(rules))
+
+	for i, rule := range rules {
+		delimiters[i] = rule.Delimiter
+	}
+
+	rdRules := *rd
+	rdRules.Delimiters = delimiters
+
+	rdRules.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		rule := rules[match.delimiterIndex]
+		match.preserve = preserveFromBool(rule.Preserve)
+
+		return rule.Replacement, nil
+	}, PreserveNone, true, []byte(nil))
+
+	rd.bytesRead = rdRules.bytesRead
+}
+
+// ReplaceWithMap function replaces every occurrence whose value is a key of a lookup map with its mapped value.
+// Values are looked up as they are, without trimming or normalizing them, and regions
+// whose value is not in the map are emitted verbatim.
+func (rd *Redel) ReplaceWithMap(m map[string][]byte, preserveDelimiters bool, mapFunc ReplacementMapFunc) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		if replacement, ok := m[string(match.value)]; ok {
+			return replacement, nil
+		}
+
+		return nil, ErrSkipRegion
+	}, preserveFromBool(preserveDelimiters), true, []byte(nil))
+}
+
+// ReplaceDispatch function replaces every occurrence with the value returned by the replace function
+// of its delimiter. Regions of delimiters without replace function keep their value.
+func (rd *Redel) ReplaceDispatch(mapFunc ReplacementMapFunc) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		if match.delimiter.Replace != nil {
+			return match.delimiter.Replace(match.value), nil
+		}
+
+		return match.value, nil
+	}, PreserveNone, true, []byte(nil))
+}
+
+// ReplaceWithHash function replaces every occurrence with the prefix followed by the hex encoded hash of its value,
+// so equal values are always replaced by the same token. A new hash is created per region via the hash function.
+func (rd *Redel) ReplaceWithHash(h func() hash.Hash, prefix []byte, mapFunc ReplacementMapFunc) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		hasher := h()
+		hasher.Write(match.value)
+
+		result := append([]byte{}, prefix...)
+
+		return hex.AppendEncode(result, hasher.Sum(nil)), nil
+	}, PreserveNone, true, []byte(nil))
+}
+
+// ReplacePerDelimiter function replaces every occurrence with the replacement of its delimiter
+// or with a default replacement token if the delimiter has no replacement.
+func (rd *Redel) ReplacePerDelimiter(defaultReplacement []byte, mapFunc ReplacementMapFunc) {
+	rd.replaceFilterFunc(mapFuncUntil(mapFunc), func(match *regionMatch) ([]byte, error) {
+		if match.delimiter.Replacement != nil {
+			return match.delimiter.Replacement, nil
+		}
+
+		return defaultReplacement, nil
+	}, PreserveNone, true, []byte(nil))
+}
+
+// All function returns an iterator which yields every matched region lazily in stream order.
+// Breaking out of the loop stops reading the data. Errors found by the scanner are not reported,
+// so use `AllWithErrors` in order to know whether every region was yielded.
+func (rd *Redel) All() iter.Seq[Match] {
+	return func(yield func(Match) bool) {
+		rd.allMatches(yield)
+	}
+}
+
+// AllWithErrors function works like `All` but once every matched region is yielded with a `nil` error,
+// it yields a zero `Match` with the first non-EOF error found by the scanner, if any.
+func (rd *Redel) AllWithErrors() iter.Seq2[Match, error] {
+	return func(yield func(Match, error) bool) {
+		err := rd.allMatches(func(match Match) bool {
+			return yield(match, nil)
+		})
+
+		if err != nil {
+			yield(Match{}, err)
+		}
+	}
+}
+
+// allMatches calls a yield function with every matched region until it returns `false`.
+// It returns the first non-EOF error found by the scanner.
+func (rd *Redel) allMatches(yield func(Match) bool) error {
+	matchIndex := 0
+
+	rdPositions := *rd
+	rdPositions.positions = true
+
+	err := rdPositions.scanTokens(func(token scanToken) bool {
+		if token.region == nil {
+			return true
+		}
+
+		match := rd.newMatch(token.region.value, token.region.delimiter, matchIndex,
+			token.offset+int64(token.region.fromIndex), token.line, token.column)
+		matchIndex++
+
+		return yield(match)
+	})
+
+	rd.bytesRead = rdPositions.bytesRead
+
+	return err
+}
+
+// newMatch returns a match with a copy of its value and the source reader of its offset.
+func (rd *Redel) newMatch(value []byte, delimiter Delimiter, index int, offset int64, line int, column int) Match {
+	match := Match{
+		Value:     bytes.Clone(value),
+		Delimiter: delimiter,
+		Index:     index,
+		Offset:    offset,
+		Line:      line,
+		Column:    column,
+	}
+
+	if rd.multi != nil {
+		match.Source = rd.multi.source(offset)
+	}
+
+	return match
+}
+
+// ReplaceAllWithMatches function replaces every occurrence with a custom replacement token like `ReplaceAll`
+// returning also every replaced region (`Match`) in a single pass.
+func (rd *Redel) ReplaceAllWithMatches(replacement []byte) ([]byte, []Match, error) {
+	var result []byte
+	var matches []Match
+
+	rdPositions := *rd
+	rdPositions.positions = true
+
+	err := rdPositions.replaceFilterFunc(func(data []byte, atEOF bool) bool {
+		result = append(result, data...)
+		return true
+	}, func(match *regionMatch) ([]byte, error) {
+		matches = append(matches, rd.newMatch(match.value, match.delimiter, match.index,
+			match.offset, match.line, match.column))
+
+		return nil, nil
+	}, PreserveNone, false, replacement)
+
+	rd.bytesRead = rdPositions.bytesRead
+
+	return result, matches, err
+}
+
+// ReplaceWithMapping function replaces every occurrence with a custom replacement token like `ReplaceAll`
+// returning also the input and output offsets of every replaced region in a single pass, so input and output
+// positions can be mapped in both directions. It returns the first non-EOF error found by the scanner.
+func (rd *Redel) ReplaceWithMapping(replacement []byte) ([]byte, []OffsetPair, error) {
+	var output []byte
+	var mapping []OffsetPair
+
+	err := rd.replaceParts(replacement, func(data []byte) bool {
+		output = append(output, data...)
+		return true
+	}, func(value []byte, replacement []byte, inputStart int64, inputEnd int64) bool {
+		outputStart := int64(len(output))
+		output = append(output, replacement...)
+
+		mapping = append(mapping, OffsetPair{
+			InputStart:  inputStart,
+			InputEnd:    inputEnd,
+			OutputStart: outputStart,
+			OutputEnd:   int64(len(output)),
+		})
+
+		return true
+	})
+
+	return output, mapping, err
+}
+
+// Count function returns the number of matched regions without copying values or calling any callback.
+func (rd *Redel) Count() (int, error) {
+	count := 0
+
+	err := rd.scanTokens(func(token scanToken) bool {
+		if token.region != nil {
+			count++
+		}
+
+		return true
+	})
+
+	return count, err
+}
+
+// CountByDelimiter function returns the number of matched regions per delimiter without copying values
+// or calling any callback. It's keyed by the delimiter start and end values joined by an ellipsis, E.g. `(…)`.
+// Delimiters without matched regions are not included.
+func (rd *Redel) CountByDelimiter() (map[string]int, error) {
+	counts := make(map[string]int)
+
+	err := rd.scanTokens(func(token scanToken) bool {
+		if token.region != nil {
+			del := token.region.delimiter
+			counts[string(del.Start)+"…"+string(del.End)]++
+		}
+
+		return true
+	})
+
+	return counts, err
+}
+
+// CollectValues function returns a copy of every matched value in stream order.
+func (rd *Redel) CollectValues() ([][]byte, error) {
+	var values [][]byte
+
+	err := rd.scanTokens(func(token scanToken) bool {
+		if token.region != nil {
+			values = append(values, bytes.Clone(token.region.value))
+		}
+
+		return true
+	})
+
+	return values, err
+}
+
+// Validate function scans the data without replacing reporting the delimiters with at least one
+// unclosed start, that is a start value emitted as text since its end value was not found (E.g. at the end of the stream).
+// Start values which are not matched on purpose (E.g. out of the value length bounds) are not reported.
+// Delimiters are returned in declaration order and it returns the first non-EOF error found by the scanner.
+func (rd *Redel) Validate() ([]Delimiter, error) {
+	unbalanced := make([]bool, len(rd.Delimiters))
+
+	err := rd.scanTokens(func(token scanToken) bool {
+		for _, delIndex := range token.unclosed {
+			unbalanced[delIndex] = true
+		}
+
+		return true
+	})
+
+	var delimiters []Delimiter
+
+	for i, del := range rd.Delimiters {
+		if unbalanced[i] {
+			delimiters = append(delimiters, del)
+		}
+	}
+
+	return delimiters, err
 }
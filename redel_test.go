@@ -1,9 +1,24 @@
 package redel
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 const STR = "(Lorem ( ) ipsum dolor [ nam risus ] magna ( suscipit. ) varius { sapien }."
@@ -82,6 +97,7 @@ func TestReplaceFilterWithString(t *testing.T) {
 
 	rep := New(r, delimiters)
 
+	// The first region is `(Lorem ( )` whose value `Lorem ( ` keeps its inner `(` and its trailing space
 	expectedStr := "Lorem (  ipsum dolor CUSTOM magna  suscipit.  varius CUSTOM."
 	replaceWithThis := []byte("CUSTOM")
 
@@ -130,3 +146,4367 @@ func TestReplaceFilterWithPreserveString(t *testing.T) {
 		t.Fatal("5. (ReplaceFilterWith + preserve delimiters) Failed to match strings!")
 	}
 }
+
+func TestReplaceFilterWithIndexString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	expectedStr := "REPL_0 ipsum dolor REPL_1 magna REPL_2 varius REPL_3."
+	expectedValues := []string{"Lorem ( ", " nam risus ", " suscipit. ", " sapien "}
+
+	output := ""
+	var values []string
+
+	filterFunc := func(matchValue []byte, index int) []byte {
+		values = append(values, string(matchValue))
+		return []byte("REPL_" + strconv.Itoa(index))
+	}
+
+	rep.ReplaceFilterWithIndex(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, false)
+
+	if output != expectedStr {
+		t.Fatal("6. (ReplaceFilterWithIndex + no preserve delimiters) Failed to match strings!")
+	}
+
+	if strings.Join(values, "|") != strings.Join(expectedValues, "|") {
+		t.Fatal("6. (ReplaceFilterWithIndex + no preserve delimiters) Failed to match values order!")
+	}
+}
+
+func TestReplaceStringTinyBuffer(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+	rep.SetBufferSize(2, 64)
+
+	expectedStr := "REPLACEMENT ipsum dolor REPLACEMENT magna REPLACEMENT varius REPLACEMENT."
+	replacement := []byte("REPLACEMENT")
+	output := ""
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("7. (Replace + tiny buffer) Failed to match strings!")
+	}
+}
+
+func TestReplaceFilterPreserveStringTinyBufferMultiByte(t *testing.T) {
+	str := "Lorem ipsum dolor START nam risus END magna START suscipit. END varius START sapien END."
+	r := strings.NewReader(str)
+
+	rep := New(r, []Delimiter{
+		{Start: []byte("START"), End: []byte("END")},
+	})
+	rep.SetBufferSize(3, 32)
+
+	expectedStr := "Lorem ipsum dolor STARTREPLACEMENTEND magna STARTREPLACEMENTEND varius STARTREPLACEMENTEND."
+	replacement := []byte("REPLACEMENT")
+	output := ""
+
+	filterFunc := func(matchValue []byte) bool {
+		return true
+	}
+
+	rep.ReplaceFilter(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, true)
+
+	if output != expectedStr {
+		t.Fatal("8. (ReplaceFilter + preserve delimiters + tiny buffer) Failed to match strings!")
+	}
+}
+
+func TestReplaceStringLongTextSmallBuffer(t *testing.T) {
+	text := strings.Repeat("Lorem ipsum dolor ", 100)
+	str := text + "[ nam risus ]" + text
+	r := strings.NewReader(str)
+
+	rep := New(r, delimiters)
+	rep.SetBufferSize(16, 32)
+
+	expectedStr := text + "REPLACEMENT" + text
+	replacement := []byte("REPLACEMENT")
+	output := ""
+	eofCalls := 0
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+
+		if atEOF {
+			eofCalls++
+		}
+	})
+
+	if output != expectedStr {
+		t.Fatal("9. (Replace + text longer than the buffer) Failed to match strings!")
+	}
+
+	if eofCalls != 1 {
+		t.Fatal("9. (Replace + text longer than the buffer) Failed to signal the last token!")
+	}
+}
+
+func TestReplacePerDelimiterString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, []Delimiter{
+		{Start: []byte("["), End: []byte("]"), Replacement: []byte("B")},
+		{Start: []byte("{"), End: []byte("}"), Replacement: []byte("C")},
+		{Start: []byte("("), End: []byte(")"), Replacement: []byte("A")},
+	})
+
+	expectedStr := "A ipsum dolor B magna A varius C."
+	output := ""
+
+	rep.ReplacePerDelimiter([]byte("DEFAULT"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("10. (ReplacePerDelimiter) Failed to match strings!")
+	}
+}
+
+func TestReplacePerDelimiterDefaultString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, []Delimiter{
+		{Start: []byte("["), End: []byte("]"), Replacement: []byte("B")},
+		{Start: []byte("{"), End: []byte("}")},
+		{Start: []byte("("), End: []byte(")"), Replacement: []byte("A")},
+	})
+
+	expectedStr := "A ipsum dolor B magna A varius DEFAULT."
+	output := ""
+
+	rep.ReplacePerDelimiter([]byte("DEFAULT"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("11. (ReplacePerDelimiter + default replacement) Failed to match strings!")
+	}
+}
+
+func TestReplaceFilterWithPreserveOptionsString(t *testing.T) {
+	str := `const a = require("foo");`
+
+	cases := []struct {
+		preserve    Preserve
+		expectedStr string
+	}{
+		{PreserveNone, `const a = bar;`},
+		{PreserveStart, `const a = require("bar;`},
+		{PreserveEnd, `const a = bar");`},
+		{PreserveBoth, `const a = require("bar");`},
+	}
+
+	for _, c := range cases {
+		r := strings.NewReader(str)
+
+		rep := New(r, []Delimiter{
+			{Start: []byte(`require("`), End: []byte(`")`)},
+		})
+
+		output := ""
+
+		filterFunc := func(matchValue []byte) []byte {
+			return []byte("bar")
+		}
+
+		rep.ReplaceFilterWithPreserve(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, filterFunc, c.preserve)
+
+		if output != c.expectedStr {
+			t.Fatalf("12. (ReplaceFilterWithPreserve + preserve option %d) Failed to match strings!", c.preserve)
+		}
+	}
+}
+
+func TestReplaceFilterPreserveOptionsString(t *testing.T) {
+	str := `const a = require("foo");`
+
+	cases := []struct {
+		preserve    Preserve
+		expectedStr string
+	}{
+		{PreserveNone, `const a = REPLACEMENT;`},
+		{PreserveStart, `const a = require("REPLACEMENT;`},
+		{PreserveEnd, `const a = REPLACEMENT");`},
+		{PreserveBoth, `const a = require("REPLACEMENT");`},
+	}
+
+	for _, c := range cases {
+		r := strings.NewReader(str)
+
+		rep := New(r, []Delimiter{
+			{Start: []byte(`require("`), End: []byte(`")`)},
+		})
+
+		output := ""
+
+		filterFunc := func(matchValue []byte) bool {
+			return true
+		}
+
+		rep.ReplaceFilterPreserve([]byte("REPLACEMENT"), func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, filterFunc, c.preserve)
+
+		if output != c.expectedStr {
+			t.Fatalf("13. (ReplaceFilterPreserve + preserve option %d) Failed to match strings!", c.preserve)
+		}
+	}
+}
+
+func TestReplaceStringFromScanner(t *testing.T) {
+	value := strings.Repeat("nam risus ", 10000)
+	str := "Lorem ipsum dolor [" + value + "] magna."
+
+	scanner := bufio.NewScanner(strings.NewReader(str))
+	scanner.Buffer(make([]byte, 0, 1024), 1024*1024)
+
+	rep := NewFromScanner(scanner, delimiters)
+
+	expectedStr := "Lorem ipsum dolor REPLACEMENT magna."
+	replacement := []byte("REPLACEMENT")
+	output := ""
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("14. (Replace + configured scanner) Failed to match strings!")
+	}
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += n
+	return n, err
+}
+
+func TestReplaceUntilString(t *testing.T) {
+	str := "Lorem ipsum dolor [ nam risus ] magna" + strings.Repeat(" ( suscipit. ) varius", 1000)
+	r := &countingReader{reader: strings.NewReader(str)}
+
+	rep := New(r, delimiters)
+	rep.SetBufferSize(16, 64)
+
+	expectedStr := "Lorem ipsum dolor REPLACEMENT"
+	replacement := []byte("REPLACEMENT")
+	output := ""
+
+	rep.ReplaceUntil(replacement, func(data []byte, atEOF bool) bool {
+		output = output + string(data)
+		return !strings.Contains(output, "REPLACEMENT")
+	})
+
+	if output != expectedStr {
+		t.Fatal("15. (ReplaceUntil) Failed to match strings!")
+	}
+
+	if r.count >= len(str) {
+		t.Fatal("15. (ReplaceUntil) Failed to halt the reading!")
+	}
+}
+
+func TestReplaceWriter(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	var w bytes.Buffer
+
+	expectedStr := "REPLACEMENT ipsum dolor REPLACEMENT magna REPLACEMENT varius REPLACEMENT."
+	replacement := []byte("REPLACEMENT")
+
+	n, err := Replace(&w, r, delimiters, replacement)
+
+	if err != nil {
+		t.Fatal("16. (Replace + writer) Failed with error:", err)
+	}
+
+	if w.String() != expectedStr {
+		t.Fatal("16. (Replace + writer) Failed to match strings!")
+	}
+
+	if n != int64(len(expectedStr)) {
+		t.Fatal("16. (Replace + writer) Failed to match bytes written!")
+	}
+}
+
+func TestReplaceFilterWithErrString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	expectedStr := "REPLACEMENT ipsum dolor REPLACEMENT"
+	expectedErr := errors.New("lookup failed")
+
+	output := ""
+	calls := 0
+
+	filterFunc := func(matchValue []byte) ([]byte, error) {
+		calls++
+
+		if calls == 3 {
+			return nil, expectedErr
+		}
+
+		return []byte("REPLACEMENT"), nil
+	}
+
+	err := rep.ReplaceFilterWithErr(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, false)
+
+	if err != expectedErr {
+		t.Fatal("17. (ReplaceFilterWithErr) Failed to return the filter error!")
+	}
+
+	if output != expectedStr || calls != 3 {
+		t.Fatal("17. (ReplaceFilterWithErr) Failed to stop the processing!")
+	}
+}
+
+func TestReplaceFilterWithErrSkipString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	expectedStr := "REPLACEMENT ipsum dolor [ nam risus ] magna REPLACEMENT varius REPLACEMENT."
+
+	output := ""
+
+	filterFunc := func(matchValue []byte) ([]byte, error) {
+		if bytes.Equal(matchValue, []byte(" nam risus ")) {
+			return nil, ErrSkipRegion
+		}
+
+		return []byte("REPLACEMENT"), nil
+	}
+
+	err := rep.ReplaceFilterWithErr(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, false)
+
+	if err != nil {
+		t.Fatal("18. (ReplaceFilterWithErr + skip region) Failed with error:", err)
+	}
+
+	if output != expectedStr {
+		t.Fatal("18. (ReplaceFilterWithErr + skip region) Failed to match strings!")
+	}
+}
+
+func TestReplaceStringValueLengthBounds(t *testing.T) {
+	str := "Lorem (a) ipsum [ nam risus ] dolor (" + strings.Repeat("x", 100) + ") magna (abc)."
+	r := strings.NewReader(str)
+
+	rep := New(r, delimiters)
+	rep.SetValueLengthBounds(3, 20)
+
+	expectedStr := "Lorem (a) ipsum REPLACEMENT dolor (" + strings.Repeat("x", 100) + ") magna REPLACEMENT."
+	replacement := []byte("REPLACEMENT")
+	output := ""
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("19. (Replace + value length bounds) Failed to match strings!")
+	}
+}
+
+func TestReplaceStringValueLengthMinBound(t *testing.T) {
+	r := strings.NewReader("Lorem (a) ipsum (abc) dolor (ab).")
+
+	rep := New(r, delimiters)
+	rep.SetValueLengthBounds(3, 0)
+
+	expectedStr := "Lorem (a) ipsum REPLACEMENT dolor (ab)."
+	replacement := []byte("REPLACEMENT")
+	output := ""
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("20. (Replace + value length min bound) Failed to match strings!")
+	}
+}
+
+func TestAllMatches(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	expectedValues := []string{"Lorem ( ", " nam risus ", " suscipit. ", " sapien "}
+	var values []string
+
+	for match := range rep.All() {
+		if match.Index != len(values) {
+			t.Fatal("21. (All) Failed to match indexes!")
+		}
+
+		values = append(values, string(match.Value))
+	}
+
+	if strings.Join(values, "|") != strings.Join(expectedValues, "|") {
+		t.Fatal("21. (All) Failed to match values!")
+	}
+}
+
+func TestAllMatchesBreak(t *testing.T) {
+	str := STR + strings.Repeat(" ( suscipit. ) varius", 1000)
+	r := &countingReader{reader: strings.NewReader(str)}
+
+	rep := New(r, delimiters)
+	rep.SetBufferSize(16, 64)
+
+	var values []string
+
+	for match := range rep.All() {
+		values = append(values, string(match.Value))
+
+		if len(values) == 2 {
+			break
+		}
+	}
+
+	if strings.Join(values, "|") != "Lorem ( | nam risus " {
+		t.Fatal("22. (All + break) Failed to match values!")
+	}
+
+	if r.count >= len(str) {
+		t.Fatal("22. (All + break) Failed to halt the reading!")
+	}
+}
+
+func TestAllMatchesWithErrors(t *testing.T) {
+	errRead := errors.New("read failure")
+	r := io.MultiReader(strings.NewReader(STR), iotest.ErrReader(errRead))
+
+	rep := New(r, delimiters)
+
+	var values []string
+	var errs []error
+
+	for match, err := range rep.AllWithErrors() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		values = append(values, string(match.Value))
+	}
+
+	if strings.Join(values, "|") != "Lorem ( | nam risus | suscipit. | sapien " {
+		t.Fatalf("117. (AllWithErrors) Failed to match values: %q", values)
+	}
+
+	if len(errs) != 1 || errs[0] != errRead {
+		t.Fatalf("117. (AllWithErrors) Failed to yield the error: %v", errs)
+	}
+
+	// Breaking out of the loop yields nothing else
+	rep = New(io.MultiReader(strings.NewReader(STR), iotest.ErrReader(errRead)), delimiters)
+	count := 0
+
+	for range rep.AllWithErrors() {
+		count++
+		break
+	}
+
+	if count != 1 {
+		t.Fatal("117. (AllWithErrors + break) Failed to stop yielding!")
+	}
+}
+
+func TestReplaceLeadingRegionString(t *testing.T) {
+	str := "(foo)bar"
+	replacement := []byte("REPLACEMENT")
+
+	filterFunc := func(matchValue []byte) bool {
+		return true
+	}
+
+	output := ""
+
+	New(strings.NewReader(str), delimiters).Replace(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != "REPLACEMENTbar" {
+		t.Fatal("23. (Replace + leading region) Failed to match strings!")
+	}
+
+	output = ""
+
+	New(strings.NewReader(str), delimiters).ReplaceFilter(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, false)
+
+	if output != "REPLACEMENTbar" {
+		t.Fatal("23. (ReplaceFilter + leading region) Failed to match strings!")
+	}
+
+	output = ""
+
+	New(strings.NewReader(str), delimiters).ReplaceFilter(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, true)
+
+	if output != "(REPLACEMENT)bar" {
+		t.Fatal("23. (ReplaceFilter + preserve delimiters + leading region) Failed to match strings!")
+	}
+}
+
+func TestReplaceFilterWithParallelString(t *testing.T) {
+	str := strings.Repeat(STR, 2)
+	r := strings.NewReader(str)
+
+	rep := New(r, delimiters)
+
+	expectedStr := strings.Repeat("<Lorem ( > ipsum dolor < nam risus > magna < suscipit. > varius < sapien >.", 2)
+	delay := 50 * time.Millisecond
+	output := ""
+
+	filterFunc := func(matchValue []byte) []byte {
+		time.Sleep(delay)
+		return []byte("<" + string(matchValue) + ">")
+	}
+
+	start := time.Now()
+
+	rep.ReplaceFilterWithParallel(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, false, 4)
+
+	elapsed := time.Since(start)
+
+	if output != expectedStr {
+		t.Fatal("24. (ReplaceFilterWithParallel) Failed to match strings!")
+	}
+
+	if elapsed >= 8*delay {
+		t.Fatal("24. (ReplaceFilterWithParallel) Failed to call the filter concurrently!")
+	}
+}
+
+func TestReplaceFilterWithEOLString(t *testing.T) {
+	str := "user=joe\npassword=secret\nhost=localhost\r\npassword=abc\r\nport=80\npassword=last"
+	r := strings.NewReader(str)
+
+	rep := New(r, []Delimiter{
+		{Start: []byte("password="), End: EOL},
+	})
+
+	expectedStr := "user=joe\npassword=***\nhost=localhost\r\npassword=***\r\nport=80\npassword=***"
+	expectedValues := []string{"secret", "abc", "last"}
+
+	output := ""
+	var values []string
+
+	filterFunc := func(matchValue []byte) []byte {
+		values = append(values, string(matchValue))
+		return []byte("***")
+	}
+
+	rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, true)
+
+	if output != expectedStr {
+		t.Fatal("25. (ReplaceFilterWith + end of line delimiter) Failed to match strings!")
+	}
+
+	if strings.Join(values, "|") != strings.Join(expectedValues, "|") {
+		t.Fatal("25. (ReplaceFilterWith + end of line delimiter) Failed to match values!")
+	}
+}
+
+func TestReplaceStringReset(t *testing.T) {
+	inputs := []string{STR, "Lorem [ipsum] dolor {sit} amet"}
+	replacement := []byte("REPLACEMENT")
+
+	rep := New(nil, delimiters)
+	rep.SetBufferSize(8, 128)
+
+	for _, str := range inputs {
+		expectedStr := ""
+
+		fresh := New(strings.NewReader(str), delimiters)
+		fresh.Replace(replacement, func(data []byte, atEOF bool) {
+			expectedStr = expectedStr + string(data)
+		})
+
+		output := ""
+
+		rep.Reset(strings.NewReader(str))
+		rep.Replace(replacement, func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if output != expectedStr {
+			t.Fatal("26. (Replace + reset) Failed to match strings!")
+		}
+	}
+}
+
+func TestReplaceStringMatchFunc(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	replacement := []byte("REPLACEMENT")
+	matches := 0
+
+	rep.SetMatchFunc(func(value []byte, replaced []byte) {
+		if !bytes.Equal(replaced, replacement) {
+			t.Fatal("27. (Replace + match function) Failed to match the replacement!")
+		}
+
+		matches++
+	})
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {})
+
+	if matches != 4 {
+		t.Fatal("27. (Replace + match function) Failed to match the number of regions!")
+	}
+}
+
+func TestReplaceTrailingRegionString(t *testing.T) {
+	str := "foo(bar)"
+	replacement := []byte("REPLACEMENT")
+
+	cases := []struct {
+		name        string
+		replaceFunc func(rep *Redel, mapFunc ReplacementMapFunc)
+		expectedStr string
+	}{
+		{"Replace", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.Replace(replacement, mapFunc)
+		}, "fooREPLACEMENT"},
+		{"ReplaceFilter", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilter(replacement, mapFunc, func(matchValue []byte) bool { return true }, false)
+		}, "fooREPLACEMENT"},
+		{"ReplaceFilter + preserve delimiters", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilter(replacement, mapFunc, func(matchValue []byte) bool { return true }, true)
+		}, "foo(REPLACEMENT)"},
+		{"ReplaceFilterWith", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilterWith(mapFunc, func(matchValue []byte) []byte { return replacement }, false)
+		}, "fooREPLACEMENT"},
+		{"ReplaceFilterWith + preserve delimiters", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilterWith(mapFunc, func(matchValue []byte) []byte { return replacement }, true)
+		}, "foo(REPLACEMENT)"},
+	}
+
+	for _, c := range cases {
+		output := ""
+		lastAtEOF := false
+
+		c.replaceFunc(New(strings.NewReader(str), delimiters), func(data []byte, atEOF bool) {
+			output = output + string(data)
+			lastAtEOF = atEOF
+		})
+
+		if output != c.expectedStr {
+			t.Fatalf("28. (%s + trailing region) Failed to match strings!", c.name)
+		}
+
+		if !lastAtEOF {
+			t.Fatalf("28. (%s + trailing region) Failed to signal the last token!", c.name)
+		}
+	}
+}
+
+func TestReplaceTemplateString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	expectedStr := "<<Lorem ( >> ipsum dolor << nam risus >> magna << suscipit. >> varius << sapien >>."
+	output := ""
+
+	rep.ReplaceTemplate([]byte("<<{{value}}>>"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("29. (ReplaceTemplate + value) Failed to match strings!")
+	}
+}
+
+func TestReplaceTemplateLenString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	expectedStr := "[redacted:8-chars] ipsum dolor [redacted:11-chars] magna [redacted:11-chars] varius [redacted:8-chars]."
+	output := ""
+
+	rep.ReplaceTemplate([]byte("[redacted:{{len}}-chars]"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("30. (ReplaceTemplate + length) Failed to match strings!")
+	}
+}
+
+func TestCount(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+
+	count, err := rep.Count()
+
+	if err != nil {
+		t.Fatal("31. (Count) Failed with error:", err)
+	}
+
+	if count != 4 {
+		t.Fatal("31. (Count) Failed to match the number of regions!")
+	}
+}
+
+func BenchmarkCount(b *testing.B) {
+	str := strings.Repeat(STR, 1000)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+
+	for i := 0; i < b.N; i++ {
+		rep := New(strings.NewReader(str), delimiters)
+
+		if _, err := rep.Count(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReplace(b *testing.B) {
+	str := strings.Repeat(STR, 1000)
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+
+	for i := 0; i < b.N; i++ {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.Replace(replacement, func(data []byte, atEOF bool) {})
+	}
+}
+
+func BenchmarkReplaceNoMatches(b *testing.B) {
+	str := strings.Repeat("Lorem ipsum dolor nam risus magna suscipit varius sapien. ", 20000)
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+
+	for i := 0; i < b.N; i++ {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.Replace(replacement, func(data []byte, atEOF bool) {})
+	}
+}
+
+func BenchmarkReplaceNoMatchesLineEnding(b *testing.B) {
+	str := strings.Repeat("Lorem ipsum dolor nam risus magna suscipit varius sapien.\r\n", 20000)
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+
+	for i := 0; i < b.N; i++ {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetLineEnding(LineEndingLF)
+		rep.Replace(replacement, func(data []byte, atEOF bool) {})
+	}
+}
+
+func BenchmarkReplaceStripLiteralDelimiters(b *testing.B) {
+	str := strings.Repeat("Lorem }} ipsum {{ dolor }} nam {{risus}} magna }}{{ suscipit. ", 10000)
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+
+	for i := 0; i < b.N; i++ {
+		rep := New(strings.NewReader(str), []Delimiter{{Start: []byte("{{"), End: []byte("}}")}})
+		rep.Replace(replacement, func(data []byte, atEOF bool) {})
+	}
+}
+
+// largeFixture returns a generated input of about 4 MiB mixing text and regions of every delimiter.
+func largeFixture() string {
+	var sb strings.Builder
+
+	for i := 0; sb.Len() < 4<<20; i++ {
+		sb.WriteString("Lorem ipsum dolor sit amet, consectetur adipiscing elit ")
+		sb.WriteString([]string{"[", "{", "("}[i%3])
+		sb.WriteString(strconv.Itoa(i))
+		sb.WriteString([]string{"]", "}", ")"}[i%3])
+		sb.WriteString(" sed do eiusmod tempor incididunt ut labore.\n")
+	}
+
+	return sb.String()
+}
+
+func BenchmarkReplaceLargeCallback(b *testing.B) {
+	str := largeFixture()
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.Replace(replacement, func(data []byte, atEOF bool) {})
+	}
+}
+
+func BenchmarkReplaceLargeWriter(b *testing.B) {
+	str := largeFixture()
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Replace(io.Discard, strings.NewReader(str), delimiters, replacement); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReplaceLargeNewWriter(b *testing.B) {
+	str := largeFixture()
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(io.Discard, delimiters, replacement)
+
+		if _, err := io.Copy(w, strings.NewReader(str)); err != nil {
+			b.Fatal(err)
+		}
+
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReplaceLargeAll(b *testing.B) {
+	str := largeFixture()
+	replacement := []byte("REPLACEMENT")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(str)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rep := New(strings.NewReader(str), delimiters)
+
+		if _, err := rep.ReplaceAll(replacement); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReplaceStringAddDelimiters(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, nil)
+
+	if err := rep.AddDelimiter([]byte("["), []byte("]")); err != nil {
+		t.Fatal("32. (Replace + add delimiters) Failed with error:", err)
+	}
+
+	if err := rep.AddDelimiters(delimiters[1:]...); err != nil {
+		t.Fatal("32. (Replace + add delimiters) Failed with error:", err)
+	}
+
+	if err := rep.AddDelimiter([]byte("<"), nil); err != ErrEmptyDelimiter {
+		t.Fatal("32. (Replace + add delimiters) Failed to reject an empty delimiter!")
+	}
+
+	if err := rep.AddDelimiters(Delimiter{Start: []byte("<"), End: []byte(">")}, Delimiter{End: []byte(">")}); err != ErrEmptyDelimiter {
+		t.Fatal("32. (Replace + add delimiters) Failed to reject an empty delimiter!")
+	}
+
+	expectedStr := "REPLACEMENT ipsum dolor REPLACEMENT magna REPLACEMENT varius REPLACEMENT."
+	replacement := []byte("REPLACEMENT")
+	output := ""
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if len(rep.Delimiters) != 3 || output != expectedStr {
+		t.Fatal("32. (Replace + add delimiters) Failed to match strings!")
+	}
+}
+
+func TestNewValidated(t *testing.T) {
+	cases := []struct {
+		delimiters  []Delimiter
+		expectedErr error
+	}{
+		{delimiters, nil},
+		{[]Delimiter{{Start: []byte("("), End: []byte("")}}, ErrEmptyDelimiter},
+		{[]Delimiter{{Start: []byte(""), End: []byte(")")}}, ErrEmptyDelimiter},
+		{[]Delimiter{
+			{Start: []byte("("), End: []byte(")")},
+			{Start: []byte("("), End: []byte(")")},
+		}, ErrDuplicateDelimiter},
+		// prefix start values are resolved by the tie-break mode
+		{[]Delimiter{
+			{Start: []byte("<"), End: []byte(">")},
+			{Start: []byte("<<"), End: []byte(">>")},
+		}, nil},
+		{[]Delimiter{
+			{Start: []byte("("), End: []byte(")")},
+			{Start: []byte("("), End: []byte("]")},
+		}, ErrAmbiguousDelimiter},
+	}
+
+	for i, c := range cases {
+		rep, err := NewValidated(strings.NewReader(STR), c.delimiters)
+
+		if err != c.expectedErr {
+			t.Fatalf("33. (NewValidated case %d) Failed to match the error!", i)
+		}
+
+		if (err == nil) != (rep != nil) {
+			t.Fatalf("33. (NewValidated case %d) Failed to match the instance!", i)
+		}
+	}
+
+	rep, err := NewValidated(strings.NewReader("<a> <<b>> <c>"), []Delimiter{
+		{Start: []byte("<"), End: []byte(">")},
+		{Start: []byte("<<"), End: []byte(">>")},
+	})
+
+	if err != nil {
+		t.Fatal("33. (NewValidated) Failed to accept prefix start delimiters!", err)
+	}
+
+	if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != "X X X" {
+		t.Fatalf("33. (NewValidated) Failed to match strings: %q", output)
+	}
+}
+
+func TestReplaceFilterWithRetainedValues(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+	rep.SetBufferSize(4, 64)
+
+	expectedValues := []string{"Lorem ( ", " nam risus ", " suscipit. ", " sapien "}
+	expectedTokens := []string{"X", " ipsum dolor X", " magna X", " varius X", "."}
+
+	var values [][]byte
+	var tokens [][]byte
+
+	filterFunc := func(matchValue []byte) []byte {
+		values = append(values, matchValue)
+		return []byte("X")
+	}
+
+	rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+		tokens = append(tokens, data)
+	}, filterFunc, false)
+
+	for i, value := range values {
+		if i >= len(expectedValues) || string(value) != expectedValues[i] {
+			t.Fatal("34. (ReplaceFilterWith + retained values) Failed to match values!")
+		}
+	}
+
+	output := ""
+
+	for _, token := range tokens {
+		output = output + string(token)
+	}
+
+	if len(values) != len(expectedValues) || output != strings.Join(expectedTokens, "") {
+		t.Fatal("34. (ReplaceFilterWith + retained values) Failed to match strings!")
+	}
+}
+
+func TestReplaceStringFlushThreshold(t *testing.T) {
+	str := strings.Repeat(STR, 100)
+	replacement := []byte("REPLACEMENT")
+
+	expectedStr := ""
+	expectedWrites := 0
+
+	New(strings.NewReader(str), delimiters).Replace(replacement, func(data []byte, atEOF bool) {
+		expectedStr = expectedStr + string(data)
+		expectedWrites++
+	})
+
+	rep := New(strings.NewReader(str), delimiters)
+	rep.SetFlushThreshold(1024)
+
+	output := ""
+	writes := 0
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		if !atEOF && len(data) < 1024 {
+			t.Fatal("35. (Replace + flush threshold) Failed to coalesce the data!")
+		}
+
+		output = output + string(data)
+		writes++
+	})
+
+	if output != expectedStr {
+		t.Fatal("35. (Replace + flush threshold) Failed to match strings!")
+	}
+
+	if writes >= expectedWrites || writes > len(expectedStr)/1024+1 {
+		t.Fatal("35. (Replace + flush threshold) Failed to reduce the number of writes!")
+	}
+}
+
+func TestReplaceStringReplaceRange(t *testing.T) {
+	replacement := []byte("REPLACEMENT")
+
+	cases := []struct {
+		from        int
+		to          int
+		expectedStr string
+	}{
+		{2, 2, "(Lorem ( ) ipsum dolor REPLACEMENT magna ( suscipit. ) varius { sapien }."},
+		{2, 3, "(Lorem ( ) ipsum dolor REPLACEMENT magna REPLACEMENT varius { sapien }."},
+		{3, 0, "(Lorem ( ) ipsum dolor [ nam risus ] magna REPLACEMENT varius REPLACEMENT."},
+	}
+
+	for _, c := range cases {
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetReplaceRange(c.from, c.to)
+
+		output := ""
+
+		rep.Replace(replacement, func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if output != c.expectedStr {
+			t.Fatalf("36. (Replace + replace range %d..%d) Failed to match strings!", c.from, c.to)
+		}
+	}
+}
+
+func TestReplaceFilterWithMultiByteDelimitersString(t *testing.T) {
+	str := "<p><!--comment--></p><!---->x<!-->y--><!--a-->"
+	r := strings.NewReader(str)
+
+	rep := New(r, []Delimiter{
+		{Start: []byte("<!--"), End: []byte("-->")},
+	})
+
+	expectedStr := "<p>[comment]</p>[]x[>y][a]"
+	expectedValues := []string{"comment", "", ">y", "a"}
+
+	output := ""
+	var values []string
+
+	filterFunc := func(matchValue []byte) []byte {
+		values = append(values, string(matchValue))
+		return []byte("[" + string(matchValue) + "]")
+	}
+
+	rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, false)
+
+	if output != expectedStr {
+		t.Fatal("37. (ReplaceFilterWith + multi-byte delimiters) Failed to match strings!")
+	}
+
+	if strings.Join(values, "|") != strings.Join(expectedValues, "|") {
+		t.Fatal("37. (ReplaceFilterWith + multi-byte delimiters) Failed to match values!")
+	}
+}
+
+func TestReplaceFilterWithEscapedString(t *testing.T) {
+	str := `{"name": "{{ name }}", "path": "{{ path }}"}`
+	r := strings.NewReader(str)
+
+	rep := New(r, []Delimiter{
+		{Start: []byte("{{"), End: []byte("}}")},
+	})
+
+	expectedStr := `{"name": "say \"hi\"", "path": "C:\\temp\n"}`
+	values := map[string]string{
+		" name ": `say "hi"`,
+		" path ": "C:\\temp\n",
+	}
+
+	output := ""
+
+	filterFunc := func(matchValue []byte) []byte {
+		return []byte(values[string(matchValue)])
+	}
+
+	rep.ReplaceFilterWithEscaped(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, filterFunc, JSONEscape, false)
+
+	if output != expectedStr {
+		t.Fatal("38. (ReplaceFilterWithEscaped + JSON escape) Failed to match strings!")
+	}
+
+	var decoded map[string]string
+
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil || decoded["name"] != values[" name "] {
+		t.Fatal("38. (ReplaceFilterWithEscaped + JSON escape) Failed to produce a valid JSON document!")
+	}
+}
+
+func TestReplaceStringWordBoundary(t *testing.T) {
+	str := "hidden=1; width=10; id=5; valid=2; id=7"
+	replacement := []byte("REPLACEMENT")
+
+	cases := []struct {
+		wordBoundary bool
+		expectedStr  string
+	}{
+		{true, "hidden=1; width=10; REPLACEMENT valid=2; id=7"},
+		{false, "hidden=1; width=10; REPLACEMENT valREPLACEMENT id=7"},
+	}
+
+	for _, c := range cases {
+		rep := New(strings.NewReader(str), []Delimiter{
+			{Start: []byte("id="), End: []byte(";"), WordBoundary: c.wordBoundary},
+		})
+
+		output := ""
+
+		rep.Replace(replacement, func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if output != c.expectedStr {
+			t.Fatalf("39. (Replace + word boundary %t) Failed to match strings!", c.wordBoundary)
+		}
+	}
+}
+
+func TestReplaceStringWordBoundaryWord(t *testing.T) {
+	str := "width id x end; ids y end; id z end"
+	rep := New(strings.NewReader(str), []Delimiter{
+		{Start: []byte("id"), End: []byte("end"), WordBoundary: true},
+	})
+	rep.SetBufferSize(2, 64)
+
+	expectedStr := "width REPLACEMENT; ids y end; REPLACEMENT"
+	output := ""
+
+	rep.Replace([]byte("REPLACEMENT"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("40. (Replace + word boundary + word delimiters) Failed to match strings!")
+	}
+}
+
+func TestReplaceStringCollapseAdjacent(t *testing.T) {
+	replacement := []byte("REPL")
+
+	cases := []struct {
+		str         string
+		expectedStr string
+	}{
+		{"(a)(b)", "REPL"},
+		{"x (a) (b)\\n\\t[c] y", "x REPL y"},
+		{"x (a) - (b) y", "x REPL - REPL y"},
+		{"x (a) (b) ", "x REPL "},
+	}
+
+	for _, c := range cases {
+		str := strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(c.str)
+		expectedStr := strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(c.expectedStr)
+
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetCollapseAdjacent(true)
+		rep.SetBufferSize(2, 64)
+
+		output := ""
+
+		rep.Replace(replacement, func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if output != expectedStr {
+			t.Fatalf("41. (Replace + collapse adjacent %q) Failed to match strings!", c.str)
+		}
+
+		rep = New(strings.NewReader(str), delimiters)
+		rep.SetCollapseAdjacent(true)
+		rep.SetBufferSize(2, 64)
+
+		output = ""
+
+		rep.ReplaceFilterWithParallel(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, func(matchValue []byte) []byte {
+			return replacement
+		}, false, 4)
+
+		if output != expectedStr {
+			t.Fatalf("41. (ReplaceFilterWithParallel + collapse adjacent %q) Failed to match strings: %q", c.str, output)
+		}
+	}
+}
+
+func TestReplaceFilterWithCollapseAdjacentDistinct(t *testing.T) {
+	r := strings.NewReader("(a) (b) (b)")
+
+	rep := New(r, delimiters)
+	rep.SetCollapseAdjacent(true)
+
+	expectedStr := "A B"
+	output := ""
+
+	rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, func(matchValue []byte) []byte {
+		return bytes.ToUpper(matchValue)
+	}, false)
+
+	if output != expectedStr {
+		t.Fatal("42. (ReplaceFilterWith + collapse adjacent) Failed to match strings!")
+	}
+}
+
+func TestApplyRulesString(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, nil)
+
+	rules := []Rule{
+		{Delimiter: delimiters[0], Replacement: []byte("B"), Preserve: true},
+		{Delimiter: delimiters[1], Replacement: []byte("C"), Preserve: false},
+		{Delimiter: delimiters[2], Replacement: []byte("A"), Preserve: true},
+	}
+
+	expectedStr := "(A) ipsum dolor [B] magna (A) varius C."
+	output := ""
+
+	rep.ApplyRules(rules, func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("43. (ApplyRules) Failed to match strings!")
+	}
+}
+
+// setEOFToken sets a fixed EOF token in order to make tests deterministic.
+func (rd *Redel) setEOFToken(eof []byte) {
+	rd.eof = eof
+}
+
+func TestReplaceStringFixedEOFToken(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	rep := New(r, delimiters)
+	rep.setEOFToken([]byte("<EOF>"))
+
+	expectedStr := "REPLACEMENT ipsum dolor REPLACEMENT magna REPLACEMENT varius REPLACEMENT."
+	replacement := []byte("REPLACEMENT")
+
+	var tokens []string
+	var eofs []bool
+
+	rep.Replace(replacement, func(data []byte, atEOF bool) {
+		tokens = append(tokens, string(data))
+		eofs = append(eofs, atEOF)
+	})
+
+	if strings.Join(tokens, "") != expectedStr {
+		t.Fatal("44. (Replace + fixed EOF token) Failed to match strings!")
+	}
+
+	if len(eofs) != 5 || tokens[4] != "." || !eofs[4] {
+		t.Fatal("44. (Replace + fixed EOF token) Failed to match the last token!")
+	}
+
+	for _, atEOF := range eofs[:4] {
+		if atEOF {
+			t.Fatal("44. (Replace + fixed EOF token) Failed to match the last token!")
+		}
+	}
+}
+
+func TestReplaceTrailingDataString(t *testing.T) {
+	replacement := []byte("X")
+
+	modes := []struct {
+		name        string
+		replaceFunc func(rep *Redel, mapFunc ReplacementMapFunc)
+	}{
+		{"Replace", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.Replace(replacement, mapFunc)
+		}},
+		{"ReplaceFilter", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilter(replacement, mapFunc, func(matchValue []byte) bool { return true }, false)
+		}},
+		{"ReplaceFilter + preserve delimiters", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilter(replacement, mapFunc, func(matchValue []byte) bool { return true }, true)
+		}},
+		{"ReplaceFilterWith", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilterWith(mapFunc, func(matchValue []byte) []byte { return replacement }, false)
+		}},
+		{"ReplaceFilterWith + preserve delimiters", func(rep *Redel, mapFunc ReplacementMapFunc) {
+			rep.ReplaceFilterWith(mapFunc, func(matchValue []byte) []byte { return replacement }, true)
+		}},
+	}
+
+	inputs := []struct {
+		str  string
+		tail string
+	}{
+		{"", ""},
+		{"no delimiters at all", "no delimiters at all"},
+		{"no delimiters at all\n", "no delimiters at all\n"},
+		{"(a) trailing text", " trailing text"},
+		{"(a) trailing newline\n", " trailing newline\n"},
+		{"(a)\n", "\n"},
+		{"(a)\r\n\r\n", "\r\n\r\n"},
+		{"[a] unclosed ( start\n", " unclosed ( start\n"},
+	}
+
+	for _, m := range modes {
+		for _, size := range []int{0, 2} {
+			for _, in := range inputs {
+				rep := New(strings.NewReader(in.str), delimiters)
+
+				if size > 0 {
+					rep.SetBufferSize(size, 64)
+				}
+
+				output := ""
+
+				m.replaceFunc(rep, func(data []byte, atEOF bool) {
+					output = output + string(data)
+				})
+
+				if !strings.HasSuffix(output, in.tail) || (in.str == in.tail && output != in.str) {
+					t.Fatalf("45. (%s + trailing data %q) Failed to match strings!", m.name, in.str)
+				}
+
+				if strings.Count(output, in.tail) != strings.Count(in.str, in.tail) {
+					t.Fatalf("45. (%s + trailing data %q) Failed to emit the trailing data once!", m.name, in.str)
+				}
+			}
+		}
+	}
+}
+
+func TestChainReaders(t *testing.T) {
+	r := strings.NewReader(STR)
+
+	reader := Chain(r, []func(io.Reader) io.Reader{
+		func(r io.Reader) io.Reader {
+			return NewReader(r, delimiters, []byte("<X>"))
+		},
+		func(r io.Reader) io.Reader {
+			return NewReader(r, []Delimiter{{Start: []byte("<"), End: []byte(">")}}, []byte("Y"))
+		},
+	})
+
+	expectedStr := "Y ipsum dolor Y magna Y varius Y."
+
+	output, err := io.ReadAll(reader)
+
+	if err != nil {
+		t.Fatal("46. (Chain) Failed with error:", err)
+	}
+
+	if string(output) != expectedStr {
+		t.Fatal("46. (Chain) Failed to match strings!")
+	}
+}
+
+func TestAllMatchesPositions(t *testing.T) {
+	str := "first (a) line\nsecond [b]\n\nñandú {c} (d)"
+
+	cases := []struct {
+		byteColumns bool
+		expected    [][3]int
+	}{
+		{false, [][3]int{{6, 1, 7}, {22, 2, 8}, {35, 4, 7}, {39, 4, 11}}},
+		{true, [][3]int{{6, 1, 7}, {22, 2, 8}, {35, 4, 9}, {39, 4, 13}}},
+	}
+
+	for _, c := range cases {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetBufferSize(2, 64)
+		rep.SetByteColumns(c.byteColumns)
+
+		var positions [][3]int
+
+		for match := range rep.All() {
+			positions = append(positions, [3]int{int(match.Offset), match.Line, match.Column})
+		}
+
+		if len(positions) != len(c.expected) {
+			t.Fatal("47. (All + positions) Failed to match the number of regions!")
+		}
+
+		for i, p := range positions {
+			if p != c.expected[i] {
+				t.Fatalf("47. (All + positions, byte columns %t) Failed to match the position of region %d: %v", c.byteColumns, i, p)
+			}
+		}
+	}
+}
+
+func TestReplaceFilterWithContextString(t *testing.T) {
+	str := "a = (x);\n// b = (y);\nc = (z)"
+
+	expectedStr := "a = (X);\n// b = (y);\nc = (Z)"
+	// contexts are shorter near the edges of the stream
+	expectedContexts := []string{"a = |;\n// b ", "// b = |;\nc = (", ");\nc = |"}
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetBufferSize(size, 64)
+		rep.SetContextWindow(7)
+
+		output := ""
+		var contexts []string
+
+		rep.ReplaceFilterWithContext(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, func(value []byte, before []byte, after []byte) []byte {
+			contexts = append(contexts, string(before)+"|"+string(after))
+
+			// regions inside line comments are left untouched
+			line := before[bytes.LastIndexByte(before, '\n')+1:]
+
+			if bytes.Contains(line, []byte("//")) {
+				return value
+			}
+
+			return bytes.ToUpper(value)
+		}, true)
+
+		if output != expectedStr {
+			t.Fatal("48. (ReplaceFilterWithContext + preserve delimiters) Failed to match strings!")
+		}
+
+		if strings.Join(contexts, ",") != strings.Join(expectedContexts, ",") {
+			t.Fatalf("48. (ReplaceFilterWithContext + preserve delimiters) Failed to match contexts: %q", contexts)
+		}
+	}
+}
+
+func TestReplaceFilterWithDeleteString(t *testing.T) {
+	expectedStr := "(Lorem ( ) ipsum dolor  magna ( suscipit. ) varius ."
+
+	filterFunc := func(matchValue []byte) []byte {
+		if bytes.Equal(matchValue, []byte(" nam risus ")) || bytes.Equal(matchValue, []byte(" sapien ")) {
+			return Delete
+		}
+
+		return matchValue
+	}
+
+	for _, preserveDelimiters := range []bool{true, false} {
+		output := ""
+		outputParallel := ""
+
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, filterFunc, preserveDelimiters)
+
+		rep = New(strings.NewReader(STR), delimiters)
+		rep.ReplaceFilterWithParallel(func(data []byte, atEOF bool) {
+			outputParallel = outputParallel + string(data)
+		}, filterFunc, preserveDelimiters, 2)
+
+		if preserveDelimiters && output != expectedStr {
+			t.Fatal("49. (ReplaceFilterWith + delete) Failed to match strings!")
+		}
+
+		if output != outputParallel {
+			t.Fatal("49. (ReplaceFilterWithParallel + delete) Failed to match strings!")
+		}
+
+		if strings.ContainsAny(output, "[]{}") {
+			t.Fatal("49. (ReplaceFilterWith + delete) Failed to remove delimiters!")
+		}
+	}
+}
+
+func TestSplitFuncTokens(t *testing.T) {
+	rep := New(nil, delimiters)
+
+	scanner := bufio.NewScanner(strings.NewReader(STR))
+	scanner.Buffer(make([]byte, 0, 4), 64)
+	scanner.Split(rep.SplitFunc())
+
+	var tokens []string
+
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal("50. (SplitFunc) Failed to scan tokens!", err)
+	}
+
+	if strings.Join(tokens, "") != STR {
+		t.Fatal("50. (SplitFunc) Failed to keep the whole data!")
+	}
+
+	// Every region ends a token
+	var regions []string
+
+	for _, token := range tokens {
+		if strings.HasSuffix(token, ")") || strings.HasSuffix(token, "]") || strings.HasSuffix(token, "}") {
+			regions = append(regions, token)
+		}
+	}
+
+	expected := []string{"(Lorem ( )", "[ nam risus ]", "( suscipit. )", "{ sapien }"}
+
+	if len(regions) != len(expected) {
+		t.Fatalf("50. (SplitFunc) Failed to match token boundaries: %q", tokens)
+	}
+
+	for i, region := range regions {
+		if !strings.HasSuffix(region, expected[i]) {
+			t.Fatalf("50. (SplitFunc) Failed to match token boundaries: %q", tokens)
+		}
+	}
+}
+
+func TestReplaceStringUntilEOF(t *testing.T) {
+	str := "code (a)\n__END__\ndata (b)\nmore data"
+
+	if _, err := NewValidated(nil, []Delimiter{{Start: []byte("__END__")}}); err != ErrEmptyDelimiter {
+		t.Fatal("51. (Replace + until EOF) Failed to reject an empty end delimiter!")
+	}
+
+	dels := []Delimiter{
+		{Start: []byte("("), End: []byte(")")},
+		{Start: []byte("__END__"), UntilEOF: true},
+	}
+
+	if _, err := NewValidated(nil, dels); err != nil {
+		t.Fatal("51. (Replace + until EOF) Failed to accept an empty end delimiter until EOF!")
+	}
+
+	expectedStr := "code X\nX"
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(str), dels)
+		rep.SetBufferSize(size, 64)
+
+		output := ""
+
+		rep.Replace([]byte("X"), func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if output != expectedStr {
+			t.Fatal("51. (Replace + until EOF) Failed to match strings!")
+		}
+	}
+
+	// An empty end delimiter without opt-in is ignored
+	rep := New(strings.NewReader(str), []Delimiter{{Start: []byte("__END__")}})
+
+	output := ""
+
+	rep.Replace([]byte("X"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != str {
+		t.Fatal("51. (Replace + empty end delimiter) Failed to match strings!")
+	}
+}
+
+type recordingObserver struct {
+	matches  []string
+	replaces []string
+	bytes    int
+}
+
+func (o *recordingObserver) OnMatch(delimiter Delimiter, value []byte) {
+	o.matches = append(o.matches, string(delimiter.Start)+string(value))
+}
+
+func (o *recordingObserver) OnReplace(value []byte, replacement []byte) {
+	o.replaces = append(o.replaces, string(value)+"="+string(replacement))
+}
+
+func (o *recordingObserver) OnBytes(n int) {
+	o.bytes += n
+}
+
+func TestReplaceStringObserver(t *testing.T) {
+	observer := &recordingObserver{}
+
+	rep := New(strings.NewReader(STR), delimiters)
+	rep.SetBufferSize(2, 64)
+	rep.SetObserver(observer)
+	rep.SetReplaceRange(2, 0)
+
+	rep.Replace([]byte("X"), func(data []byte, atEOF bool) {})
+
+	expectedMatches := []string{"(Lorem ( ", "[ nam risus ", "( suscipit. ", "{ sapien "}
+	expectedReplaces := []string{" nam risus =X", " suscipit. =X", " sapien =X"}
+
+	if strings.Join(observer.matches, "|") != strings.Join(expectedMatches, "|") {
+		t.Fatal("52. (Replace + observer) Failed to match OnMatch calls!")
+	}
+
+	if strings.Join(observer.replaces, "|") != strings.Join(expectedReplaces, "|") {
+		t.Fatal("52. (Replace + observer) Failed to match OnReplace calls!")
+	}
+
+	if observer.bytes != len(STR) {
+		t.Fatal("52. (Replace + observer) Failed to match OnBytes calls!")
+	}
+
+	// Counting reports matches but no replacements
+	observer = &recordingObserver{}
+
+	rep = New(strings.NewReader(STR), delimiters)
+	rep.SetObserver(observer)
+
+	if _, err := rep.Count(); err != nil || len(observer.matches) != 4 || len(observer.replaces) != 0 {
+		t.Fatal("52. (Count + observer) Failed to match observer calls!")
+	}
+}
+
+func TestReplaceStringFencedBlocks(t *testing.T) {
+	str := "text\n```\ncode one\n```\nmiddle\n```\ncode two\n```\nend ```"
+	fences := []Delimiter{{Start: []byte("```"), End: []byte("```")}}
+
+	expectedStr := "text\n```\nCODE ONE\n```\nmiddle\n```\nCODE TWO\n```\nend ```"
+	expectedValues := []string{"\ncode one\n", "\ncode two\n"}
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(str), fences)
+		rep.SetBufferSize(size, 64)
+
+		var values []string
+
+		for match := range rep.All() {
+			values = append(values, string(match.Value))
+		}
+
+		if strings.Join(values, "|") != strings.Join(expectedValues, "|") {
+			t.Fatal("53. (All + fenced blocks) Failed to match values!")
+		}
+
+		rep = New(strings.NewReader(str), fences)
+		rep.SetBufferSize(size, 64)
+
+		output := ""
+
+		rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, bytes.ToUpper, true)
+
+		if output != expectedStr {
+			t.Fatal("53. (ReplaceFilterWith + fenced blocks) Failed to match strings!")
+		}
+	}
+}
+
+func TestReplaceFilterStringMixed(t *testing.T) {
+	cases := []struct {
+		preserve Preserve
+		expected string
+	}{
+		{PreserveNone, "X ipsum dolor  nam risus  magna X varius  sapien ."},
+		{PreserveBoth, "(X) ipsum dolor [ nam risus ] magna (X) varius { sapien }."},
+		{PreserveStart, "(X ipsum dolor [ nam risus  magna (X varius { sapien ."},
+		{PreserveEnd, "X) ipsum dolor  nam risus ] magna X) varius  sapien }."},
+	}
+
+	for _, c := range cases {
+		rep := New(strings.NewReader(STR), delimiters)
+
+		output := ""
+		index := 0
+
+		rep.ReplaceFilterPreserve([]byte("X"), func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, func(matchValue []byte) bool {
+			index++
+			return index%2 == 1
+		}, c.preserve)
+
+		if output != c.expected {
+			t.Fatalf("54. (ReplaceFilterPreserve + mixed results, preserve %d) Failed to match strings: %q", c.preserve, output)
+		}
+	}
+}
+
+func TestReplaceWithMapString(t *testing.T) {
+	m := map[string][]byte{
+		" nam risus ": []byte("NAM"),
+		" sapien ":    []byte("SAPIEN"),
+		"sapien":      []byte("UNUSED"),
+	}
+
+	cases := []struct {
+		preserveDelimiters bool
+		expected           string
+	}{
+		{false, "(Lorem ( ) ipsum dolor NAM magna ( suscipit. ) varius SAPIEN."},
+		{true, "(Lorem ( ) ipsum dolor [NAM] magna ( suscipit. ) varius {SAPIEN}."},
+	}
+
+	for _, c := range cases {
+		rep := New(strings.NewReader(STR), delimiters)
+
+		output := ""
+
+		rep.ReplaceWithMap(m, c.preserveDelimiters, func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if output != c.expected {
+			t.Fatal("55. (ReplaceWithMap) Failed to match strings!")
+		}
+	}
+}
+
+func TestReplaceBytesNUL(t *testing.T) {
+	input := []byte("\x00head\x00\x01\x00val\x00ue\x00\x02\x00tail\x00\x01\x02\x00")
+	dels := []Delimiter{{Start: []byte("\x01\x00"), End: []byte("\x00\x02")}}
+
+	expected := []byte("\x00head\x00\x01\x00R\x00\x00\x02\x00tail\x00\x01\x02\x00")
+	expectedValue := []byte("val\x00ue")
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(bytes.NewReader(input), dels)
+		rep.SetBufferSize(size, 64)
+
+		var output []byte
+		var values [][]byte
+
+		rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+			output = append(output, data...)
+		}, func(matchValue []byte) []byte {
+			values = append(values, matchValue)
+			return []byte("R\x00")
+		}, true)
+
+		if !bytes.Equal(output, expected) {
+			t.Fatal("56. (ReplaceFilterWith + NUL bytes) Failed to match bytes!")
+		}
+
+		if len(values) != 1 || !bytes.Equal(values[0], expectedValue) {
+			t.Fatal("56. (ReplaceFilterWith + NUL bytes) Failed to match values!")
+		}
+	}
+}
+
+func TestReplaceStringTieBreak(t *testing.T) {
+	str := "<<a>> <b> <<c>>"
+
+	short := Delimiter{Start: []byte("<"), End: []byte(">")}
+	long := Delimiter{Start: []byte("<<"), End: []byte(">>")}
+
+	cases := []struct {
+		mode       TieBreak
+		delimiters []Delimiter
+		expected   string
+	}{
+		{TieBreakValueStart, []Delimiter{long, short}, "X> X X>"},
+		{TieBreakLongest, []Delimiter{short, long}, "X X X"},
+		{TieBreakLongest, []Delimiter{long, short}, "X X X"},
+		{TieBreakDeclared, []Delimiter{short, long}, "X> X X>"},
+		{TieBreakDeclared, []Delimiter{long, short}, "X X X"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(str), c.delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetTieBreak(c.mode)
+
+			output := ""
+
+			rep.Replace([]byte("X"), func(data []byte, atEOF bool) {
+				output = output + string(data)
+			})
+
+			if output != c.expected {
+				t.Fatalf("57. (Replace + tie-break %d) Failed to match strings: %q", c.mode, output)
+			}
+		}
+	}
+}
+
+type closeTrackingWriter struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *closeTrackingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestReplaceWriterFlush(t *testing.T) {
+	expectedStr := "REPL ipsum dolor REPL magna REPL varius REPL."
+
+	dst := &closeTrackingWriter{}
+	w := bufio.NewWriterSize(dst, 4096)
+
+	// The caller doesn't flush the buffered writer
+	n, err := Replace(w, strings.NewReader(STR), delimiters, []byte("REPL"))
+
+	if err != nil || n != int64(len(expectedStr)) {
+		t.Fatal("58. (Replace writer + flush) Failed to replace!", err)
+	}
+
+	if dst.String() != expectedStr {
+		t.Fatal("58. (Replace writer + flush) Failed to flush the buffered writer!")
+	}
+
+	// The writer is never closed
+	n, err = Replace(dst, strings.NewReader(STR), delimiters, []byte("REPL"))
+
+	if err != nil || n != int64(len(expectedStr)) || dst.closed {
+		t.Fatal("58. (Replace writer + closer) Failed to keep the writer open!")
+	}
+}
+
+func TestAllStrictPairing(t *testing.T) {
+	brackets := []Delimiter{
+		{Start: []byte("("), End: []byte(")")},
+		{Start: []byte("["), End: []byte("]")},
+	}
+
+	sharedStart := []Delimiter{
+		{Start: []byte("("), End: []byte(")")},
+		{Start: []byte("("), End: []byte("]")},
+	}
+
+	cases := []struct {
+		str        string
+		delimiters []Delimiter
+		expected   []string
+	}{
+		{"x (a] y", brackets, nil},
+		{"x [b) y", brackets, nil},
+		{"(a] [b)", brackets, []string{"(a] [b)"}},
+		{"[a) (b]", brackets, []string{"[a) (b]"}},
+		{"(a] (b) [c]", brackets, []string{"(a] (b)", "[c]"}},
+		{"(a] x", sharedStart, []string{"(a]"}},
+		{"(a) x (b]", sharedStart, []string{"(a)", "(b]"}},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.str), c.delimiters)
+			rep.SetBufferSize(size, 64)
+
+			var regions []string
+
+			for match := range rep.All() {
+				regions = append(regions, string(match.Delimiter.Start)+string(match.Value)+string(match.Delimiter.End))
+			}
+
+			if strings.Join(regions, "|") != strings.Join(c.expected, "|") {
+				t.Fatalf("59. (All + strict pairing) Failed to match regions of %q: %q", c.str, regions)
+			}
+		}
+	}
+}
+
+func TestReplaceTee(t *testing.T) {
+	rep := New(strings.NewReader(STR), delimiters)
+
+	expected, err := rep.ReplaceAll([]byte("REPL"))
+
+	if err != nil || string(expected) != "REPL ipsum dolor REPL magna REPL varius REPL." {
+		t.Fatal("60. (ReplaceAll) Failed to match strings!")
+	}
+
+	for _, size := range []int{1, 4, 64} {
+		var transformed, original bytes.Buffer
+
+		rep = New(strings.NewReader(STR), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		n, err := rep.ReplaceTee(&transformed, &original, []byte("REPL"))
+
+		if err != nil || n != int64(transformed.Len()) {
+			t.Fatal("60. (ReplaceTee) Failed to replace!", err)
+		}
+
+		if original.String() != STR {
+			t.Fatal("60. (ReplaceTee) Failed to match the original data!")
+		}
+
+		if !bytes.Equal(transformed.Bytes(), expected) {
+			t.Fatal("60. (ReplaceTee) Failed to match the transformed data!")
+		}
+	}
+
+	// The Redel reader is required, so a given scanner is not supported
+	rep = NewFromScanner(bufio.NewScanner(strings.NewReader(STR)), delimiters)
+
+	if _, err := rep.ReplaceTee(io.Discard, io.Discard, []byte("REPL")); err != ErrNoReader {
+		t.Fatal("60. (ReplaceTee) Failed to return the no reader error!", err)
+	}
+}
+
+func TestReplaceStringGreedy(t *testing.T) {
+	str := "x (a) text (b) y"
+
+	cases := []struct {
+		greedy   bool
+		expected []string
+	}{
+		{false, []string{"a", "b"}},
+		{true, []string{"a) text (b"}},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(str), []Delimiter{{Start: []byte("("), End: []byte(")"), Greedy: c.greedy}})
+			rep.SetBufferSize(size, 64)
+
+			var values []string
+
+			for match := range rep.All() {
+				values = append(values, string(match.Value))
+			}
+
+			if strings.Join(values, "|") != strings.Join(c.expected, "|") {
+				t.Fatalf("61. (All + greedy %t) Failed to match values: %q", c.greedy, values)
+			}
+
+			rep = New(strings.NewReader(str), []Delimiter{{Start: []byte("("), End: []byte(")"), Greedy: c.greedy}})
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			rep.Replace([]byte("X"), func(data []byte, atEOF bool) {
+				output = output + string(data)
+			})
+
+			expectedStr := "x X text X y"
+
+			if c.greedy {
+				expectedStr = "x X y"
+			}
+
+			if output != expectedStr {
+				t.Fatalf("61. (Replace + greedy %t) Failed to match strings: %q", c.greedy, output)
+			}
+		}
+	}
+}
+
+func TestReplaceStringOverlappingRegions(t *testing.T) {
+	brackets := []Delimiter{
+		{Start: []byte("("), End: []byte(")")},
+		{Start: []byte("["), End: []byte("]")},
+	}
+
+	cases := []struct {
+		str      string
+		expected string
+	}{
+		{"[a (b] c)", "X c)"},
+		{"(a [b) c]", "X c]"},
+		{"[a (b c] d)", "X d)"},
+		{"[a (b) c]", "X"},
+		{"(a [b] c", "(a X c"},
+		{"[a (b] (c)", "X X"},
+		{"x (a [b) [c] d]", "x X X d]"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.str), brackets)
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			rep.Replace([]byte("X"), func(data []byte, atEOF bool) {
+				output = output + string(data)
+			})
+
+			if output != c.expected {
+				t.Fatalf("62. (Replace + overlapping regions) Failed to match strings of %q: %q", c.str, output)
+			}
+		}
+	}
+}
+
+func TestReplaceWithHashString(t *testing.T) {
+	str := "user=(alice) token=(s3cr3t) owner=(alice)"
+
+	sum := func(value string) string {
+		h := sha256.Sum256([]byte(value))
+		return "sha:" + hex.EncodeToString(h[:])
+	}
+
+	expectedStr := "user=" + sum("alice") + " token=" + sum("s3cr3t") + " owner=" + sum("alice")
+
+	rep := New(strings.NewReader(str), delimiters)
+
+	output := ""
+
+	rep.ReplaceWithHash(sha256.New, []byte("sha:"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != expectedStr {
+		t.Fatal("63. (ReplaceWithHash) Failed to match strings!")
+	}
+
+	if sum("alice") == sum("s3cr3t") {
+		t.Fatal("63. (ReplaceWithHash) Failed to match different hashes!")
+	}
+}
+
+func TestReplaceMaxInputBytes(t *testing.T) {
+	str := strings.Repeat("a (b) ", 10000)
+
+	rep := New(strings.NewReader(str), delimiters)
+	rep.SetMaxInputBytes(60)
+
+	output, err := rep.ReplaceAll([]byte("X"))
+
+	if err != ErrInputTooLarge {
+		t.Fatal("64. (ReplaceAll + max input bytes) Failed to return the input too large error!")
+	}
+
+	if string(output) != strings.Repeat("a X ", 10) {
+		t.Fatalf("64. (ReplaceAll + max input bytes) Failed to match strings: %q", output)
+	}
+
+	// An input of exactly the maximum size is fully processed
+	rep = New(strings.NewReader(str[0:60]), delimiters)
+	rep.SetMaxInputBytes(60)
+
+	if _, err := rep.ReplaceAll([]byte("X")); err != nil {
+		t.Fatal("64. (ReplaceAll + max input bytes) Failed to process the whole input!")
+	}
+}
+
+func TestReplaceParts(t *testing.T) {
+	expected, _ := New(strings.NewReader(STR), delimiters).ReplaceAll([]byte("REPL"))
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		output := ""
+		var values []string
+
+		err := rep.ReplaceParts([]byte("REPL"), func(data []byte) {
+			output = output + string(data)
+		}, func(value []byte, replacement []byte) {
+			values = append(values, string(value))
+			output = output + "<" + string(replacement) + ">"
+		})
+
+		if err != nil {
+			t.Fatal("65. (ReplaceParts) Failed to replace!", err)
+		}
+
+		if strings.NewReplacer("<", "", ">", "").Replace(output) != string(expected) {
+			t.Fatal("65. (ReplaceParts) Failed to match strings!")
+		}
+
+		if output != "<REPL> ipsum dolor <REPL> magna <REPL> varius <REPL>." {
+			t.Fatal("65. (ReplaceParts) Failed to match stream order!")
+		}
+
+		if strings.Join(values, "|") != "Lorem ( | nam risus | suscipit. | sapien " {
+			t.Fatal("65. (ReplaceParts) Failed to match values!")
+		}
+	}
+}
+
+func TestReplaceStringReplacementWithDelimiters(t *testing.T) {
+	str := "1 (x) 2 (y)(z) 3 [w]"
+
+	cases := []struct {
+		preserveDelimiters bool
+		expected           string
+	}{
+		{false, "1 a(b)c 2 a(b)ca(b)c 3 a(b)c"},
+		{true, "1 (a(b)c) 2 (a(b)c)(a(b)c) 3 [a(b)c]"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(str), delimiters)
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(matchValue []byte) []byte {
+				return []byte("a(b)c")
+			}, c.preserveDelimiters)
+
+			if output != c.expected {
+				t.Fatalf("66. (ReplaceFilterWith + replacement with delimiters) Failed to match strings: %q", output)
+			}
+		}
+	}
+}
+
+func TestReplaceStringLineEnding(t *testing.T) {
+	str := "a\r\nb\n(x\r\ny)\r\nc\rd\n\r\n[e]\r(f)\r"
+
+	cases := []struct {
+		mode     LineEnding
+		expected string
+	}{
+		{LineEndingKeep, str},
+		{LineEndingLF, "a\nb\n(x\r\ny)\nc\rd\n\n[e]\r(f)\r"},
+		{LineEndingCRLF, "a\r\nb\r\n(x\r\ny)\r\nc\rd\r\n\r\n[e]\r(f)\r"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(str), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetLineEnding(c.mode)
+
+			output := ""
+
+			rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(matchValue []byte) []byte {
+				return matchValue
+			}, true)
+
+			if output != c.expected {
+				t.Fatalf("67. (ReplaceFilterWith + line ending %d) Failed to match strings: %q", c.mode, output)
+			}
+
+			rep = New(strings.NewReader(str), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetLineEnding(c.mode)
+
+			output = ""
+
+			rep.ReplaceFilterWithParallel(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(matchValue []byte) []byte {
+				return matchValue
+			}, true, 2)
+
+			if output != c.expected {
+				t.Fatalf("67. (ReplaceFilterWithParallel + line ending %d) Failed to match strings: %q", c.mode, output)
+			}
+		}
+	}
+}
+
+func TestNewSafeRandomDelimiters(t *testing.T) {
+	if _, err := NewSafe(nil, []Delimiter{{Start: []byte("(")}}); err != ErrEmptyDelimiter {
+		t.Fatal("68. (NewSafe) Failed to reject an empty end delimiter!")
+	}
+
+	rep, err := NewSafe(strings.NewReader(STR), delimiters)
+
+	if err != nil || rep == nil {
+		t.Fatal("68. (NewSafe) Failed to create an instance!")
+	}
+
+	const alphabet = "ab ()[]{}\r\n"
+
+	random := rand.New(rand.NewSource(1))
+
+	randomBytes := func(max int) []byte {
+		b := make([]byte, random.Intn(max+1))
+
+		for i := range b {
+			b[i] = alphabet[random.Intn(len(alphabet))]
+		}
+
+		return b
+	}
+
+	for i := 0; i < 2000; i++ {
+		input := randomBytes(64)
+		dels := make([]Delimiter, random.Intn(4))
+
+		for j := range dels {
+			dels[j] = Delimiter{
+				Start:        randomBytes(3),
+				End:          randomBytes(3),
+				WordBoundary: random.Intn(2) == 0,
+				UntilEOF:     random.Intn(4) == 0,
+				Greedy:       random.Intn(4) == 0,
+			}
+
+			if random.Intn(8) == 0 {
+				dels[j].End = EOL
+			}
+		}
+
+		// Malformed delimiters must not panic either
+		rep := New(bytes.NewReader(input), dels)
+		rep.SetBufferSize(1+random.Intn(8), 256)
+
+		output, err := rep.ReplaceAll([]byte("R"))
+
+		if err != nil {
+			t.Fatalf("68. (ReplaceAll + random delimiters) Failed to replace %q: %v", input, err)
+		}
+
+		if len(output) > len(input) {
+			t.Fatalf("68. (ReplaceAll + random delimiters) Failed to bound the output of %q: %q", input, output)
+		}
+	}
+}
+
+func FuzzReplace(f *testing.F) {
+	f.Add([]byte(STR), []byte("("), []byte(")"), 4)
+	f.Add([]byte("a ``` b ``` c"), []byte("```"), []byte("```"), 1)
+	f.Add([]byte("key=value\r\nnext"), []byte("key="), EOL, 2)
+	f.Add([]byte("<<a>> <b>"), []byte("<<"), []byte(">>"), 3)
+
+	f.Fuzz(func(t *testing.T, input []byte, start []byte, end []byte, size int) {
+		if size < 1 || size > 64 {
+			size = 1
+		}
+
+		dels := []Delimiter{{Start: start, End: end}, {Start: []byte("("), End: []byte(")")}}
+
+		rep := New(bytes.NewReader(input), dels)
+		rep.SetBufferSize(size, len(input)+len(start)+len(end)+64)
+
+		output, err := rep.ReplaceAll([]byte("R"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(output) > len(input) {
+			t.Fatalf("output %q is longer than input %q", output, input)
+		}
+
+		// Keeping values and delimiters reproduces the input
+		rep = New(bytes.NewReader(input), dels)
+		rep.SetBufferSize(size, len(input)+len(start)+len(end)+64)
+
+		var same []byte
+
+		rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+			same = append(same, data...)
+		}, func(matchValue []byte) []byte {
+			return matchValue
+		}, true)
+
+		if !bytes.Equal(same, input) {
+			t.Fatalf("output %q doesn't match input %q", same, input)
+		}
+	})
+}
+
+func TestReplaceStringAlternativeEnds(t *testing.T) {
+	dels := []Delimiter{{Start: []byte("{{"), End: []byte("}}"), AltEnds: [][]byte{[]byte("/}}")}}}
+
+	cases := []struct {
+		str      string
+		expected string
+		values   []string
+	}{
+		{"a {{x /}} y}} b", "a X y}} b", []string{"x "}},
+		{"a {{x }} y/}} b", "a X y/}} b", []string{"x "}},
+		{"{{x/}}{{y}}", "XX", []string{"x", "y"}},
+		{"{{x}}/}}{{y/}}}}", "X/}}X}}", []string{"x", "y"}},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.str), dels)
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+			var values []string
+
+			rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(matchValue []byte) []byte {
+				values = append(values, string(matchValue))
+				return []byte("X")
+			}, false)
+
+			if output != c.expected {
+				t.Fatalf("69. (ReplaceFilterWith + alternative ends) Failed to match strings of %q: %q", c.str, output)
+			}
+
+			if strings.Join(values, "|") != strings.Join(c.values, "|") {
+				t.Fatalf("69. (ReplaceFilterWith + alternative ends) Failed to match values of %q: %q", c.str, values)
+			}
+		}
+	}
+
+	if _, err := NewValidated(nil, []Delimiter{{Start: []byte("{{"), End: []byte("}}"), AltEnds: [][]byte{{}}}}); err != ErrEmptyDelimiter {
+		t.Fatal("69. (NewValidated + alternative ends) Failed to reject an empty alternative end!")
+	}
+}
+
+func TestReplaceFilterWithSeesDelimiters(t *testing.T) {
+	cases := []struct {
+		preserveDelimiters bool
+		expected           string
+	}{
+		{false, "X ipsum dolor [ nam risus ] magna X varius { sapien }."},
+		{true, "(X) ipsum dolor [[ nam risus ]] magna (X) varius {{ sapien }}."},
+	}
+
+	for _, c := range cases {
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetFilterSeesDelimiters(true)
+
+		output := ""
+		var values []string
+
+		rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, func(matchValue []byte) []byte {
+			values = append(values, string(matchValue))
+
+			if bytes.HasPrefix(matchValue, []byte("(")) && bytes.HasSuffix(matchValue, []byte(")")) {
+				return []byte("X")
+			}
+
+			return matchValue
+		}, c.preserveDelimiters)
+
+		if output != c.expected {
+			t.Fatalf("70. (ReplaceFilterWith + filter sees delimiters) Failed to match strings: %q", output)
+		}
+
+		if strings.Join(values, "|") != "(Lorem ( )|[ nam risus ]|( suscipit. )|{ sapien }" {
+			t.Fatal("70. (ReplaceFilterWith + filter sees delimiters) Failed to match values!")
+		}
+	}
+}
+
+func TestReplaceStringInterleavedOrder(t *testing.T) {
+	str := "[a]{b}(c)[d] x{e}[f](g){h}"
+
+	expectedStr := "0:a1:b2:c3:d x4:e5:f6:g7:h"
+
+	for _, size := range []int{1, 2, 3, 4, 64} {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		output := ""
+
+		rep.ReplaceFilterWithIndex(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, func(matchValue []byte, index int) []byte {
+			return []byte(strconv.Itoa(index) + ":" + string(matchValue))
+		}, false)
+
+		if output != expectedStr {
+			t.Fatalf("71. (ReplaceFilterWithIndex + interleaved delimiters) Failed to match strings: %q", output)
+		}
+
+		rep = New(strings.NewReader(str), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		offset := int64(-1)
+
+		for match := range rep.All() {
+			if match.Offset <= offset {
+				t.Fatal("71. (All + interleaved delimiters) Failed to match stream order!")
+			}
+
+			offset = match.Offset
+		}
+	}
+}
+
+func TestReplaceFilterWithDelimiterString(t *testing.T) {
+	str := "(a)[b](c)[d](e)"
+
+	expectedStr := "XbXdX"
+	expectedNumbers := []string{"(0#0", "[1#0", "(2#1", "[3#1", "(4#2"}
+
+	rep := New(strings.NewReader(str), delimiters)
+
+	output := ""
+	var numbers []string
+	sequences := map[string]int{}
+
+	rep.ReplaceFilterWithDelimiter([]byte("X"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}, func(value []byte, delimiter Delimiter, index int) bool {
+		start := string(delimiter.Start)
+		numbers = append(numbers, start+strconv.Itoa(index)+"#"+strconv.Itoa(sequences[start]))
+		sequences[start]++
+
+		return start == "("
+	}, false)
+
+	if output != expectedStr {
+		t.Fatalf("72. (ReplaceFilterWithDelimiter + no preserve delimiters) Failed to match strings: %q", output)
+	}
+
+	if strings.Join(numbers, "|") != strings.Join(expectedNumbers, "|") {
+		t.Fatal("72. (ReplaceFilterWithDelimiter + no preserve delimiters) Failed to match numbers!")
+	}
+}
+
+func TestReplaceFilterWithUnchangedValues(t *testing.T) {
+	cases := []struct {
+		str      string
+		expected string
+	}{
+		{STR, "Lorem (  ipsum dolor  nam risus  magna  suscipit.  varius  sapien ."},
+		{"a (b) c [d]{e}", "a b c de"},
+		{"(x)(y) ( z )", "xy  z "},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.str), delimiters)
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(matchValue []byte) []byte {
+				return matchValue
+			}, false)
+
+			// Only the delimiters of every region are stripped
+			if output != c.expected {
+				t.Fatalf("73. (ReplaceFilterWith + unchanged values) Failed to match strings: %q", output)
+			}
+		}
+	}
+}
+
+func TestProcessorChunks(t *testing.T) {
+	proc := NewProcessor(delimiters, []byte("X"))
+
+	var outputs []string
+
+	for _, chunk := range []string{"a (re", "gi", "on) b [c", "] d"} {
+		output, err := proc.Write([]byte(chunk))
+
+		if err != nil {
+			t.Fatal("74. (Processor) Failed to write!", err)
+		}
+
+		outputs = append(outputs, string(output))
+	}
+
+	output, err := proc.Finish()
+
+	if err != nil {
+		t.Fatal("74. (Processor) Failed to finish!", err)
+	}
+
+	outputs = append(outputs, string(output))
+
+	if strings.Join(outputs, "") != "a X b X d" {
+		t.Fatalf("74. (Processor) Failed to match strings: %q", outputs)
+	}
+
+	// Data is emitted incrementally
+	if outputs[0] != "a " || outputs[1] != "" {
+		t.Fatalf("74. (Processor) Failed to emit data incrementally: %q", outputs)
+	}
+
+	if _, err := proc.Write([]byte("e")); err != ErrProcessorFinished {
+		t.Fatal("74. (Processor) Failed to reject writes once finished!")
+	}
+}
+
+func TestAllMultiReaders(t *testing.T) {
+	sources := []string{"a (b", "c) d", "", "[e] {f", "}"}
+
+	newReaders := func() []io.Reader {
+		readers := make([]io.Reader, len(sources))
+
+		for i, source := range sources {
+			readers[i] = strings.NewReader(source)
+		}
+
+		return readers
+	}
+
+	rep := NewMulti(newReaders(), delimiters)
+	rep.SetBufferSize(1, 64)
+
+	var matches []string
+
+	for match := range rep.All() {
+		matches = append(matches, string(match.Value)+"@"+strconv.Itoa(match.Source))
+	}
+
+	if strings.Join(matches, "|") != "bc@0|e@3|f@3" {
+		t.Fatalf("75. (All + multi readers) Failed to match sources: %q", matches)
+	}
+
+	output, err := NewMulti(newReaders(), delimiters).ReplaceAll([]byte("X"))
+
+	if err != nil || string(output) != "a X dX X" {
+		t.Fatalf("75. (ReplaceAll + multi readers) Failed to match strings: %q", output)
+	}
+}
+
+func TestReplaceStringTrailingNewline(t *testing.T) {
+	cases := []struct {
+		str      string
+		mode     TrailingNewline
+		expected string
+	}{
+		{"a (b)\nc (d)\n", TrailingNewlineAsInput, "a X\nc X\n"},
+		{"a (b)\nc (d)", TrailingNewlineAsInput, "a X\nc X"},
+		{"a (b)\nc (d)\n", TrailingNewlineAlways, "a X\nc X\n"},
+		{"a (b)\nc (d)", TrailingNewlineAlways, "a X\nc X\n"},
+		{"a (b)\nc (d)\n\r\n\n", TrailingNewlineAlways, "a X\nc X\n"},
+		{"a (b)\nc (d)\n", TrailingNewlineNever, "a X\nc X"},
+		{"a (b)\nc (d)", TrailingNewlineNever, "a X\nc X"},
+		{"a (b)\n\nc (d)\n\n", TrailingNewlineNever, "a X\n\nc X"},
+		{"", TrailingNewlineAsInput, ""},
+		{"", TrailingNewlineAlways, "\n"},
+		{"", TrailingNewlineNever, ""},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.str), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetEnsureTrailingNewline(c.mode)
+
+			output, err := rep.ReplaceAll([]byte("X"))
+
+			if err != nil || string(output) != c.expected {
+				t.Fatalf("76. (ReplaceAll + trailing newline %d) Failed to match strings of %q: %q", c.mode, c.str, output)
+			}
+
+			rep = New(strings.NewReader(c.str), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetEnsureTrailingNewline(c.mode)
+
+			parts := ""
+
+			err = rep.ReplaceParts([]byte("X"), func(data []byte) {
+				parts += string(data)
+			}, func(value []byte, replacement []byte) {
+				parts += string(replacement)
+			})
+
+			if err != nil || parts != c.expected {
+				t.Fatalf("76. (ReplaceParts + trailing newline %d) Failed to match strings of %q: %q", c.mode, c.str, parts)
+			}
+
+			rep = New(strings.NewReader(c.str), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetEnsureTrailingNewline(c.mode)
+
+			parallel := ""
+
+			rep.ReplaceFilterWithParallel(func(data []byte, atEOF bool) {
+				parallel += string(data)
+			}, func(matchValue []byte) []byte {
+				return []byte("X")
+			}, false, 2)
+
+			if parallel != c.expected {
+				t.Fatalf("76. (ReplaceFilterWithParallel + trailing newline %d) Failed to match strings of %q: %q", c.mode, c.str, parallel)
+			}
+		}
+	}
+
+	// The added line ending of empty data follows the line ending option
+	rep := New(strings.NewReader(""), delimiters)
+	rep.SetEnsureTrailingNewline(TrailingNewlineAlways)
+	rep.SetLineEnding(LineEndingCRLF)
+
+	if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != "\r\n" {
+		t.Fatalf("76. (ReplaceAll + trailing newline of empty data) Failed to match strings: %q", output)
+	}
+
+	// Held line endings are emitted when the replacement stops before the end of the data
+	errFilter := errors.New("filter error")
+
+	for _, mode := range []TrailingNewline{TrailingNewlineAlways, TrailingNewlineNever} {
+		// Small buffers emit the text before the region as a separate token
+		for _, size := range []int{1, 4} {
+			rep := New(strings.NewReader("a\n\n(b) c"), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetEnsureTrailingNewline(mode)
+
+			output := ""
+
+			err := rep.ReplaceFilterWithErr(func(data []byte, atEOF bool) {
+				output += string(data)
+			}, func(matchValue []byte) ([]byte, error) {
+				return nil, errFilter
+			}, false)
+
+			if err != errFilter || output != "a\n\n" {
+				t.Fatalf("76. (ReplaceFilterWithErr + trailing newline %d) Failed to emit the held line endings: %q", mode, output)
+			}
+
+			rep = New(io.MultiReader(strings.NewReader("a\n\n\n"), &slowReader{
+				reader: strings.NewReader("(b) c"),
+				delay:  20 * time.Millisecond,
+			}), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetEnsureTrailingNewline(mode)
+			rep.SetTimeout(5 * time.Millisecond)
+
+			parts := ""
+
+			err = rep.ReplaceParts([]byte("X"), func(data []byte) {
+				parts += string(data)
+			}, func(value []byte, replacement []byte) {
+				parts += string(replacement)
+			})
+
+			if err != ErrTimeout || parts != "a\n\n" {
+				t.Fatalf("76. (ReplaceParts + timeout + trailing newline %d) Failed to emit the held line endings: %q", mode, parts)
+			}
+		}
+	}
+}
+
+func TestReplaceAllWithMatches(t *testing.T) {
+	rep := New(strings.NewReader(STR), delimiters)
+	rep.SetBufferSize(4, 64)
+
+	output, matches, err := rep.ReplaceAllWithMatches([]byte("REPL"))
+
+	if err != nil || string(output) != "REPL ipsum dolor REPL magna REPL varius REPL." {
+		t.Fatal("77. (ReplaceAllWithMatches) Failed to match strings!")
+	}
+
+	expected := []Match{
+		{Value: []byte("Lorem ( "), Delimiter: delimiters[2], Index: 0, Offset: 0, Line: 1, Column: 1},
+		{Value: []byte(" nam risus "), Delimiter: delimiters[0], Index: 1, Offset: 23, Line: 1, Column: 24},
+		{Value: []byte(" suscipit. "), Delimiter: delimiters[2], Index: 2, Offset: 43, Line: 1, Column: 44},
+		{Value: []byte(" sapien "), Delimiter: delimiters[1], Index: 3, Offset: 64, Line: 1, Column: 65},
+	}
+
+	if len(matches) != len(expected) {
+		t.Fatal("77. (ReplaceAllWithMatches) Failed to match the number of matches!")
+	}
+
+	for i, match := range matches {
+		e := expected[i]
+
+		if !bytes.Equal(match.Value, e.Value) || !bytes.Equal(match.Delimiter.Start, e.Delimiter.Start) ||
+			match.Index != e.Index || match.Offset != e.Offset || match.Line != e.Line || match.Column != e.Column {
+			t.Fatalf("77. (ReplaceAllWithMatches) Failed to match %d: %+v", i, match)
+		}
+	}
+}
+
+func TestReplaceDispatchString(t *testing.T) {
+	reverse := func(value []byte) []byte {
+		reversed := make([]byte, len(value))
+
+		for i, b := range value {
+			reversed[len(value)-1-i] = b
+		}
+
+		return reversed
+	}
+
+	dels := []Delimiter{
+		{Start: []byte("("), End: []byte(")"), Replace: bytes.ToUpper},
+		{Start: []byte("["), End: []byte("]"), Replace: reverse},
+		{Start: []byte("{"), End: []byte("}")},
+	}
+
+	rep := New(strings.NewReader(STR), dels)
+
+	output := ""
+
+	rep.ReplaceDispatch(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != "LOREM (  ipsum dolor  susir man  magna  SUSCIPIT.  varius  sapien ." {
+		t.Fatalf("78. (ReplaceDispatch) Failed to match strings: %q", output)
+	}
+}
+
+func TestReplaceStringTinyBufferLongDelimiters(t *testing.T) {
+	str := "head <<<BEGIN>>> secret value <<<END>>> middle <<<BEGIN>>>x<<<END>>> tail"
+	dels := []Delimiter{{Start: []byte("<<<BEGIN>>>"), End: []byte("<<<END>>>")}}
+
+	for size := 1; size <= 8; size++ {
+		rep := New(strings.NewReader(str), dels)
+		rep.SetBufferSize(size, 64)
+
+		output, err := rep.ReplaceAll([]byte("X"))
+
+		if err != nil || string(output) != "head X middle X tail" {
+			t.Fatalf("79. (ReplaceAll + tiny buffer %d) Failed to match strings: %q", size, output)
+		}
+	}
+
+	// The maximum buffer size must hold the longest region
+	rep := New(strings.NewReader(str), dels)
+	rep.SetBufferSize(8, 16)
+
+	if _, err := rep.ReplaceAll([]byte("X")); !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatal("79. (ReplaceAll + tiny buffer) Failed to return the too long error!", err)
+	}
+}
+
+func TestCollectValues(t *testing.T) {
+	rep := New(strings.NewReader(STR), delimiters)
+	rep.SetBufferSize(2, 64)
+
+	values, err := rep.CollectValues()
+
+	if err != nil {
+		t.Fatal("80. (CollectValues) Failed to collect values!", err)
+	}
+
+	expected := []string{"Lorem ( ", " nam risus ", " suscipit. ", " sapien "}
+
+	if len(values) != len(expected) {
+		t.Fatal("80. (CollectValues) Failed to match the number of values!")
+	}
+
+	for i, value := range values {
+		if string(value) != expected[i] {
+			t.Fatal("80. (CollectValues) Failed to match values!")
+		}
+	}
+}
+
+func TestReplaceFilterPreserveMultiCharDelimiters(t *testing.T) {
+	str := `a = require("x"); b = require("y/z")`
+	dels := []Delimiter{{Start: []byte(`require("`), End: []byte(`")`)}}
+
+	cases := []struct {
+		preserve Preserve
+		expected string
+	}{
+		{PreserveNone, `a = REPL; b = REPL`},
+		{PreserveBoth, `a = require("REPL"); b = require("REPL")`},
+		{PreserveStart, `a = require("REPL; b = require("REPL`},
+		{PreserveEnd, `a = REPL"); b = REPL")`},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(str), dels)
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			rep.ReplaceFilterPreserve([]byte("REPL"), func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(matchValue []byte) bool {
+				return true
+			}, c.preserve)
+
+			if output != c.expected {
+				t.Fatalf("81. (ReplaceFilterPreserve + multi-char delimiters, preserve %d) Failed to match strings: %q", c.preserve, output)
+			}
+		}
+	}
+}
+
+func TestReplaceFromOffset(t *testing.T) {
+	cases := []struct {
+		offset   int64
+		expected string
+	}{
+		{0, "REPL ipsum dolor REPL magna REPL varius REPL."},
+		{10, " ipsum dolor REPL magna REPL varius REPL."},
+		{30, "isus ] magna REPL varius REPL."},
+	}
+
+	for _, c := range cases {
+		rep := New(strings.NewReader(STR), delimiters)
+
+		output := ""
+		var values []string
+
+		rep.SetMatchFunc(func(value []byte, replacement []byte) {
+			values = append(values, string(value))
+		})
+
+		err := rep.ReplaceFrom(c.offset, []byte("REPL"), func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if err != nil || output != c.expected {
+			t.Fatalf("82. (ReplaceFrom) Failed to match strings: %q", output)
+		}
+
+		if len(values) != strings.Count(c.expected, "REPL") || (c.offset > 0 && values[0] == "Lorem ( ") {
+			t.Fatal("82. (ReplaceFrom) Failed to skip the regions before the offset!")
+		}
+	}
+
+	// Input offsets are absolute, so only the regions starting before the offset 40 are replaced
+	rep := New(strings.NewReader(STR), delimiters)
+	rep.SetActiveRange(0, 40)
+
+	output := ""
+
+	err := rep.ReplaceFrom(10, []byte("REPL"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if err != nil || output != " ipsum dolor REPL magna ( suscipit. ) varius { sapien }." {
+		t.Fatalf("82. (ReplaceFrom + active range) Failed to match strings: %q", output)
+	}
+
+	if rep.BytesRead() != int64(len(STR)-10) {
+		t.Fatal("82. (ReplaceFrom) Failed to count the bytes from the offset!")
+	}
+
+	rep = New(io.MultiReader(strings.NewReader(STR)), delimiters)
+
+	if err := rep.ReplaceFrom(10, []byte("REPL"), func(data []byte, atEOF bool) {}); err != ErrNotSeeker {
+		t.Fatal("82. (ReplaceFrom) Failed to reject a reader which is not a seeker!")
+	}
+}
+
+func TestReplaceEmptyValues(t *testing.T) {
+	const input = "a () b ( ) c (x)"
+
+	cases := []struct {
+		skip              bool
+		whitespaceAsEmpty bool
+		expected          string
+	}{
+		{false, false, "a EMPTY b REPL c REPL"},
+		{false, true, "a EMPTY b EMPTY c REPL"},
+		{true, false, "a () b REPL c REPL"},
+		{true, true, "a () b ( ) c REPL"},
+	}
+
+	for _, c := range cases {
+		for _, parallel := range []bool{false, true} {
+			rep := New(strings.NewReader(input), []Delimiter{{Start: []byte("("), End: []byte(")")}})
+			rep.SetEmptyValueReplacement([]byte("EMPTY"))
+			rep.SetSkipEmptyValues(c.skip)
+			rep.SetWhitespaceAsEmpty(c.whitespaceAsEmpty)
+
+			output := ""
+			mapFunc := func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}
+
+			if parallel {
+				rep.ReplaceFilterWithParallel(mapFunc, func(value []byte) []byte {
+					return []byte("REPL")
+				}, false, 2)
+			} else {
+				rep.Replace([]byte("REPL"), mapFunc)
+			}
+
+			if output != c.expected {
+				t.Fatalf("83. (EmptyValues) Failed to match strings: %q", output)
+			}
+		}
+	}
+
+	rep := New(strings.NewReader(input), []Delimiter{{Start: []byte("("), End: []byte(")")}})
+
+	output := ""
+
+	rep.Replace([]byte("REPL"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	// By default empty regions are replaced like any other one
+	if output != "a REPL b REPL c REPL" {
+		t.Fatalf("83. (EmptyValues) Failed to handle empty regions by default: %q", output)
+	}
+}
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Logf(format string, args ...any) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestReplaceLogger(t *testing.T) {
+	logger := &recordingLogger{}
+
+	rep := New(strings.NewReader(STR), delimiters)
+	rep.SetLogger(logger)
+
+	rep.Replace([]byte("REPL"), func(data []byte, atEOF bool) {})
+
+	matches := 0
+	replaced := 0
+
+	for _, msg := range logger.messages {
+		if strings.HasPrefix(msg, "redel: match ") {
+			matches++
+		}
+
+		if strings.HasPrefix(msg, "redel: region ") && strings.Contains(msg, "replaced") {
+			replaced++
+		}
+	}
+
+	if matches != 4 || replaced != 4 {
+		t.Fatalf("84. (Logger) Failed to log every region: %q", logger.messages)
+	}
+
+	if logger.messages[0] != `redel: match "Lorem ( " at offset 0` {
+		t.Fatalf("84. (Logger) Failed to log the match message: %q", logger.messages[0])
+	}
+}
+
+func TestReplaceFilterRegexp(t *testing.T) {
+	const input = "user (alice) id (12345) note (v2 beta) empty ()"
+
+	cases := []struct {
+		preserve bool
+		expected string
+	}{
+		{false, "user (alice) id *** note *** empty ()"},
+		{true, "user (alice) id (***) note (***) empty ()"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(input), []Delimiter{{Start: []byte("("), End: []byte(")")}})
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			rep.ReplaceFilterRegexp(regexp.MustCompile(`\d+`), []byte("***"), func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, c.preserve)
+
+			if output != c.expected {
+				t.Fatalf("85. (ReplaceFilterRegexp) Failed to match strings: %q", output)
+			}
+		}
+	}
+}
+
+func TestReplaceStripLiteralDelimiters(t *testing.T) {
+	// Delimiter bytes in the text before a region are literal content and must be kept
+	const input = "a }} b {x} c }}{{x}} d"
+
+	cases := []struct {
+		preserve Preserve
+		expected string
+	}{
+		{PreserveNone, "a }} b {x} c }}REPL d"},
+		{PreserveStart, "a }} b {x} c }}{{REPL d"},
+		{PreserveEnd, "a }} b {x} c }}REPL}} d"},
+		{PreserveBoth, "a }} b {x} c }}{{REPL}} d"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(input), []Delimiter{{Start: []byte("{{"), End: []byte("}}")}})
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			rep.ReplaceFilterWithPreserve(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(value []byte) []byte {
+				return []byte("REPL")
+			}, c.preserve)
+
+			if output != c.expected {
+				t.Fatalf("86. (StripLiteralDelimiters) Failed to match strings: %q", output)
+			}
+		}
+	}
+}
+
+type slowReader struct {
+	reader io.Reader
+	delay  time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	return r.reader.Read(p)
+}
+
+func TestReplaceTimeout(t *testing.T) {
+	r := &countingReader{reader: &slowReader{reader: strings.NewReader(strings.Repeat(STR, 100)), delay: 5 * time.Millisecond}}
+
+	rep := New(r, delimiters)
+	rep.SetBufferSize(16, 64)
+	rep.SetTimeout(30 * time.Millisecond)
+
+	if _, err := rep.ReplaceAll([]byte("REPL")); err != ErrTimeout {
+		t.Fatalf("87. (Timeout) Failed to abort the run: %v", err)
+	}
+
+	if r.count >= len(STR)*100 {
+		t.Fatal("87. (Timeout) Failed to stop processing!")
+	}
+
+	rep = New(strings.NewReader(STR), delimiters)
+	rep.SetTimeout(time.Second)
+
+	if _, err := rep.ReplaceAll([]byte("REPL")); err != nil {
+		t.Fatalf("87. (Timeout) Failed to finish before the timeout: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader("a [b] c (d) e (f {g} h"), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		unbalanced, err := rep.Validate()
+
+		if err != nil || len(unbalanced) != 1 || string(unbalanced[0].Start) != "(" {
+			t.Fatalf("88. (Validate) Failed to report the unbalanced delimiter: %v", unbalanced)
+		}
+	}
+
+	rep := New(strings.NewReader(STR), delimiters)
+
+	if unbalanced, err := rep.Validate(); err != nil || len(unbalanced) != 0 {
+		t.Fatalf("88. (Validate) Failed to validate balanced delimiters: %v", unbalanced)
+	}
+
+	// Start values which are not matched on purpose are not unclosed
+	cases := []struct {
+		str        string
+		delimiters []Delimiter
+		minLen     int
+	}{
+		{"(a) (bb)", delimiters, 2},
+		{"<a> <b", []Delimiter{{Start: []byte("<"), End: []byte(">"), AnchorStart: true}}, 0},
+		{"xid=1 id=2;", []Delimiter{{Start: []byte("id="), End: []byte(";"), WordBoundary: true}}, 0},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.str), c.delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetValueLengthBounds(c.minLen, 0)
+
+			if unbalanced, err := rep.Validate(); err != nil || len(unbalanced) != 0 {
+				t.Fatalf("88. (Validate %q) Failed to skip the unmatched start values: %v", c.str, unbalanced)
+			}
+		}
+	}
+
+	// A start value given up by a full buffer is unclosed
+	rep = New(strings.NewReader("{a} (abcdef) [b]"), delimiters)
+	rep.SetBufferSize(1, 4)
+	rep.SetOversizedAsText(true)
+
+	if unbalanced, err := rep.Validate(); err != nil || len(unbalanced) != 1 || string(unbalanced[0].Start) != "(" {
+		t.Fatalf("88. (Validate + oversized as text) Failed to report the given up delimiter: %v", unbalanced)
+	}
+}
+
+func TestReplaceActiveRange(t *testing.T) {
+	start := int64(strings.Index(STR, "["))
+	end := int64(strings.Index(STR, "{"))
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetBufferSize(size, 64)
+		rep.SetActiveRange(start, end)
+
+		output := ""
+		var values []string
+
+		rep.SetMatchFunc(func(value []byte, replacement []byte) {
+			values = append(values, string(value))
+		})
+
+		rep.Replace([]byte("REPL"), func(data []byte, atEOF bool) {
+			output = output + string(data)
+		})
+
+		if output != "(Lorem ( ) ipsum dolor REPL magna REPL varius { sapien }." {
+			t.Fatalf("89. (ActiveRange) Failed to match strings: %q", output)
+		}
+
+		if len(values) != 2 || values[0] != " nam risus " || values[1] != " suscipit. " {
+			t.Fatalf("89. (ActiveRange) Failed to replace only the regions in range: %q", values)
+		}
+	}
+
+	rep := New(strings.NewReader(STR), delimiters)
+	rep.SetActiveRange(start, 0)
+
+	output := ""
+
+	rep.Replace([]byte("REPL"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != "(Lorem ( ) ipsum dolor REPL magna REPL varius REPL." {
+		t.Fatalf("89. (ActiveRange) Failed to replace without upper bound: %q", output)
+	}
+}
+
+func TestReplaceFilterWithOffset(t *testing.T) {
+	expected := []int64{
+		int64(strings.Index(STR, "Lorem")),
+		int64(strings.Index(STR, " nam")),
+		int64(strings.Index(STR, " suscipit")),
+		int64(strings.Index(STR, " sapien")),
+	}
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		var offsets []int64
+
+		rep.ReplaceFilterWithOffset(func(data []byte, atEOF bool) {}, func(value []byte, offset int64) []byte {
+			if !strings.HasPrefix(STR[offset:], string(value)) {
+				t.Fatalf("90. (ReplaceFilterWithOffset) Failed to match the value at offset %d!", offset)
+			}
+
+			offsets = append(offsets, offset)
+			return value
+		}, false)
+
+		if len(offsets) != len(expected) {
+			t.Fatalf("90. (ReplaceFilterWithOffset) Failed to match the number of offsets: %v", offsets)
+		}
+
+		for i, offset := range offsets {
+			if offset != expected[i] || (i > 0 && offset <= offsets[i-1]) {
+				t.Fatalf("90. (ReplaceFilterWithOffset) Failed to match offsets: %v", offsets)
+			}
+		}
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWrapReadCloser(t *testing.T) {
+	body := io.NopCloser(strings.NewReader(STR))
+	rc := WrapReadCloser(body, delimiters, []byte("REPL"))
+
+	data, err := io.ReadAll(rc)
+
+	if err != nil || string(data) != "REPL ipsum dolor REPL magna REPL varius REPL." {
+		t.Fatalf("91. (WrapReadCloser) Failed to match strings: %q", data)
+	}
+
+	if err := rc.Close(); err != nil {
+		t.Fatal("91. (WrapReadCloser) Failed to close the body!")
+	}
+
+	recorder := &closeRecorder{Reader: strings.NewReader(strings.Repeat(STR, 1000))}
+	rc = WrapReadCloser(recorder, delimiters, []byte("REPL"))
+
+	if _, err := rc.Read(make([]byte, 8)); err != nil {
+		t.Fatal("91. (WrapReadCloser) Failed to read the body!")
+	}
+
+	if err := rc.Close(); err != nil || !recorder.closed {
+		t.Fatal("91. (WrapReadCloser) Failed to propagate Close!")
+	}
+
+	if _, err := rc.Read(make([]byte, 8)); err != io.ErrClosedPipe {
+		t.Fatal("91. (WrapReadCloser) Failed to stop reading after Close!")
+	}
+}
+
+func TestReplaceRegionTooLong(t *testing.T) {
+	value := strings.Repeat("x", 100)
+	str := "short (abc) and [" + value + "] tail"
+
+	rep := New(strings.NewReader(str), delimiters)
+	rep.SetBufferSize(4, 32)
+
+	_, err := rep.ReplaceAll([]byte("X"))
+
+	if !errors.Is(err, ErrRegionTooLong) || !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("92. (RegionTooLong) Failed to return the region too long error: %v", err)
+	}
+
+	if !strings.Contains(err.Error(), `start delimiter "["`) {
+		t.Fatalf("92. (RegionTooLong) Failed to report the start delimiter: %v", err)
+	}
+
+	rep = New(strings.NewReader(str), delimiters)
+	rep.SetBufferSize(4, 256)
+
+	if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != "short X and X tail" {
+		t.Fatalf("92. (RegionTooLong) Failed to match strings: %q", output)
+	}
+
+	// The start delimiter of a region larger than the buffer is given up on demand
+	rep = New(strings.NewReader(str), delimiters)
+	rep.SetBufferSize(4, 32)
+	rep.SetOversizedAsText(true)
+
+	if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != "short X and ["+value+"] tail" {
+		t.Fatalf("92. (RegionTooLong + oversized as text) Failed to emit the region as text: %q %v", output, err)
+	}
+}
+
+func TestDelimitersHelpers(t *testing.T) {
+	dels := Delimiters("[", "]", "{", "}", "(", ")")
+
+	if len(dels) != len(delimiters) {
+		t.Fatal("93. (Delimiters) Failed to build the delimiters!")
+	}
+
+	for i, del := range dels {
+		if !bytes.Equal(del.Start, delimiters[i].Start) || !bytes.Equal(del.End, delimiters[i].End) {
+			t.Fatalf("93. (Delimiters) Failed to match the delimiter %d!", i)
+		}
+	}
+
+	if del := Pair("<<", ">>"); string(del.Start) != "<<" || string(del.End) != ">>" {
+		t.Fatal("93. (Pair) Failed to build the delimiter!")
+	}
+
+	output, err := New(strings.NewReader(STR), dels).ReplaceAll([]byte("REPL"))
+
+	if err != nil || string(output) != "REPL ipsum dolor REPL magna REPL varius REPL." {
+		t.Fatalf("93. (Delimiters) Failed to match strings: %q", output)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("93. (Delimiters) Failed to panic with an odd number of values!")
+		}
+	}()
+
+	Delimiters("(", ")", "[")
+}
+
+func TestReplaceValueContainsStartDelimiter(t *testing.T) {
+	cases := []struct {
+		input    string
+		values   []string
+		expected string
+	}{
+		{"(a(b)", []string{"a(b"}, "X"},
+		{"((x)", []string{"(x"}, "X"},
+		{"((x) (y))", []string{"(x", "y"}, "X X)"},
+		{"-(a(b)-(c)-", []string{"a(b", "c"}, "-X-X-"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.input), Delimiters("(", ")"))
+			rep.SetBufferSize(size, 64)
+
+			output, matches, err := rep.ReplaceAllWithMatches([]byte("X"))
+
+			if err != nil || string(output) != c.expected || len(matches) != len(c.values) {
+				t.Fatalf("94. (Start delimiter in value) Failed to match strings: %q", output)
+			}
+
+			for i, match := range matches {
+				if string(match.Value) != c.values[i] {
+					t.Fatalf("94. (Start delimiter in value) Failed to match the value: %q", match.Value)
+				}
+			}
+		}
+	}
+}
+
+func TestReplaceNesting(t *testing.T) {
+	cases := []struct {
+		input    string
+		flat     []string
+		balanced []string
+	}{
+		{"( a ( b )", []string{"X", " a ( b "}, []string{"( a X", " b "}},
+		{"(a (b) c) d", []string{"X c) d", "a (b"}, []string{"X d", "a (b) c"}},
+		{"((x)) (y)", []string{"X) X", "(x", "y"}, []string{"X X", "(x)", "y"}},
+		{"(a) (b (c (d)))", []string{"X X))", "a", "b (c (d"}, []string{"X X", "a", "b (c (d))"}},
+	}
+
+	for _, c := range cases {
+		for _, mode := range []Nesting{NestingFlat, NestingBalanced} {
+			expected := c.flat
+
+			if mode == NestingBalanced {
+				expected = c.balanced
+			}
+
+			for _, size := range []int{1, 4, 64} {
+				rep := New(strings.NewReader(c.input), Delimiters("(", ")"))
+				rep.SetBufferSize(size, 64)
+				rep.SetNesting(mode)
+
+				output, matches, err := rep.ReplaceAllWithMatches([]byte("X"))
+
+				if err != nil || string(output) != expected[0] || len(matches) != len(expected)-1 {
+					t.Fatalf("95. (Nesting %d) Failed to match strings: %q", mode, output)
+				}
+
+				for i, match := range matches {
+					if string(match.Value) != expected[i+1] {
+						t.Fatalf("95. (Nesting %d) Failed to match the value: %q", mode, match.Value)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReplaceTextFunc(t *testing.T) {
+	expected := "(Lorem ( ) IPSUM DOLOR [repl] MAGNA (repl) VARIUS {repl}."
+
+	for _, size := range []int{1, 4, 64} {
+		for _, parallel := range []bool{false, true} {
+			rep := New(strings.NewReader(STR), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetReplaceRange(2, 0)
+			rep.SetTextFunc(bytes.ToUpper)
+
+			output := ""
+			mapFunc := func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}
+			filterFunc := func(value []byte) []byte {
+				return []byte("repl")
+			}
+
+			if parallel {
+				rep.ReplaceFilterWithParallel(mapFunc, filterFunc, true, 2)
+			} else {
+				rep.ReplaceFilterWith(mapFunc, filterFunc, true)
+			}
+
+			if output != expected {
+				t.Fatalf("96. (TextFunc) Failed to match strings: %q", output)
+			}
+		}
+	}
+}
+
+func TestReplacePrefixDelimitersLongestWins(t *testing.T) {
+	str := `<abbr title="x">text</a> <a href="y">link</a>`
+
+	short := Pair("<a", ">")
+	long := Pair("<abbr", ">")
+
+	for _, dels := range [][]Delimiter{{short, long}, {long, short}} {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(str), dels)
+			rep.SetBufferSize(size, 64)
+
+			output, matches, err := rep.ReplaceAllWithMatches([]byte("X"))
+
+			if err != nil || string(output) != "Xtext</a> Xlink</a>" {
+				t.Fatalf("97. (Prefix delimiters) Failed to match strings: %q", output)
+			}
+
+			if len(matches) != 2 || !bytes.Equal(matches[0].Delimiter.Start, long.Start) ||
+				string(matches[0].Value) != ` title="x"` || !bytes.Equal(matches[1].Delimiter.Start, short.Start) {
+				t.Fatal("97. (Prefix delimiters) Failed to prefer the longest start delimiter!")
+			}
+		}
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	for _, size := range []int{1, 3, 7, 64} {
+		var out bytes.Buffer
+		w := NewWriter(&out, delimiters, []byte("REPL"))
+
+		for i := 0; i < len(STR); i += size {
+			chunk := STR[i:min(i+size, len(STR))]
+
+			if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+				t.Fatalf("98. (NewWriter) Failed to write the chunk: %v", err)
+			}
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("98. (NewWriter) Failed to close: %v", err)
+		}
+
+		if out.String() != "REPL ipsum dolor REPL magna REPL varius REPL." {
+			t.Fatalf("98. (NewWriter) Failed to match strings: %q", out.String())
+		}
+
+		if err := w.Close(); err != ErrProcessorFinished {
+			t.Fatal("98. (NewWriter) Failed to reject a second Close!")
+		}
+	}
+
+	var out bytes.Buffer
+	buf := bufio.NewWriter(&out)
+	w := NewWriter(buf, delimiters, []byte("REPL"))
+
+	if _, err := io.Copy(w, strings.NewReader(STR)); err != nil || w.Close() != nil {
+		t.Fatal("98. (NewWriter + io.Copy) Failed to copy the data!")
+	}
+
+	if out.String() != "REPL ipsum dolor REPL magna REPL varius REPL." {
+		t.Fatalf("98. (NewWriter + io.Copy) Failed to match strings: %q", out.String())
+	}
+}
+
+func TestReplaceFilterWithDynamicPreserve(t *testing.T) {
+	str := "a (b) c [d] e (f) g [h] i (j)"
+	expected := "a (X) c X e () g  i (j)"
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		output := ""
+
+		rep.ReplaceFilterWithDynamicPreserve(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, func(value []byte) ([]byte, bool) {
+			switch string(value) {
+			case "f":
+				// only the value is removed
+				return nil, true
+			case "h":
+				return Delete, true
+			case "j":
+				return value, true
+			}
+
+			// parentheses keep their delimiters while brackets don't
+			return []byte("X"), value[0] < 'd'
+		})
+
+		if output != expected {
+			t.Fatalf("99. (ReplaceFilterWithDynamicPreserve) Failed to match strings: %q", output)
+		}
+	}
+}
+
+func TestBytesRead(t *testing.T) {
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		rep.Replace([]byte("LONGER REPLACEMENT"), func(data []byte, atEOF bool) {})
+
+		if rep.BytesRead() != int64(len(STR)) {
+			t.Fatalf("100. (BytesRead) Failed to match the number of bytes: %d", rep.BytesRead())
+		}
+	}
+
+	rep := New(strings.NewReader(STR), delimiters)
+
+	if _, _, err := rep.ReplaceAllWithMatches([]byte("X")); err != nil || rep.BytesRead() != int64(len(STR)) {
+		t.Fatalf("100. (BytesRead + ReplaceAllWithMatches) Failed to match the number of bytes: %d", rep.BytesRead())
+	}
+
+	rep = New(strings.NewReader(STR), delimiters)
+
+	if err := rep.ReplaceFrom(10, []byte("X"), func(data []byte, atEOF bool) {}); err != nil ||
+		rep.BytesRead() != int64(len(STR)-10) {
+		t.Fatalf("100. (BytesRead + ReplaceFrom) Failed to match the number of bytes: %d", rep.BytesRead())
+	}
+}
+
+func TestRunStaged(t *testing.T) {
+	cases := []struct {
+		replacement string
+		expected    string
+	}{
+		{"X", "X ipsum dolor B magna X varius B."},
+		// a replacement of the first stage is matched by the second stage
+		{"[x]", "B ipsum dolor B magna B varius B."},
+	}
+
+	for _, c := range cases {
+		for _, oneByte := range []bool{false, true} {
+			var r io.Reader = strings.NewReader(STR)
+
+			if oneByte {
+				r = iotest.OneByteReader(r)
+			}
+
+			rep := New(r, nil)
+			rep.AddStage(Delimiters("(", ")"), []byte(c.replacement))
+			rep.AddStage(Delimiters("[", "]", "{", "}"), []byte("B"))
+
+			output := ""
+
+			err := rep.RunStaged(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			})
+
+			if err != nil || output != c.expected {
+				t.Fatalf("101. (RunStaged) Failed to match strings: %q", output)
+			}
+		}
+	}
+
+	// A region of the second stage spanning several tokens of the first stage is matched
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader("<a (b) c (d) e> (f)"), nil)
+		rep.SetBufferSize(size, 64)
+		rep.AddStage(Delimiters("(", ")"), []byte("X"))
+		rep.AddStage(Delimiters("<", ">"), []byte("B"))
+
+		output := ""
+
+		if err := rep.RunStaged(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}); err != nil || output != "B X" {
+			t.Fatalf("101. (RunStaged + spanning region) Failed to match strings: %q", output)
+		}
+	}
+
+	rep := New(strings.NewReader(STR), nil)
+	output := ""
+
+	if err := rep.RunStaged(func(data []byte, atEOF bool) {
+		output = output + string(data)
+	}); err != nil || output != STR {
+		t.Fatalf("101. (RunStaged) Failed to pass the data through without stages: %q", output)
+	}
+
+	// The Redel reader is required, so a given scanner is not supported
+	rep = NewFromScanner(bufio.NewScanner(strings.NewReader(STR)), nil)
+	rep.AddStage(Delimiters("(", ")"), []byte("X"))
+
+	if err := rep.RunStaged(func(data []byte, atEOF bool) {}); err != ErrNoReader {
+		t.Fatal("101. (RunStaged) Failed to return the no reader error!", err)
+	}
+}
+
+func TestReplaceEOLKeepsLineEndings(t *testing.T) {
+	str := "key=abc\r\nname=joe\r\nkey=\r\nkey=x\nkey=last"
+	expected := "REPL\r\nname=joe\r\nREPL\r\nREPL\nREPL"
+	expectedValues := []string{"abc", "", "x", "last"}
+
+	for _, size := range []int{1, 4, 64} {
+		for _, preserve := range []Preserve{PreserveNone, PreserveEnd} {
+			rep := New(strings.NewReader(str), []Delimiter{{Start: []byte("key="), End: EOL}})
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+			var values []string
+
+			rep.ReplaceFilterWithPreserve(func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}, func(value []byte) []byte {
+				values = append(values, string(value))
+				return []byte("REPL")
+			}, preserve)
+
+			if output != expected {
+				t.Fatalf("102. (EOL + CRLF) Failed to match strings: %q", output)
+			}
+
+			if strings.Join(values, "|") != strings.Join(expectedValues, "|") {
+				t.Fatalf("102. (EOL + CRLF) Failed to match values: %q", values)
+			}
+		}
+	}
+
+	// A plain line feed end value is a regular end value, so the line ending is part of the region
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader("k=v\nrest k=w\r\nend"), []Delimiter{{Start: []byte("k="), End: []byte("\n")}})
+		rep.SetBufferSize(size, 64)
+
+		output := ""
+		var values []string
+
+		rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, func(value []byte) []byte {
+			values = append(values, string(value))
+			return []byte("R")
+		}, false)
+
+		if output != "Rrest Rend" || strings.Join(values, "|") != "v|w\r" {
+			t.Fatalf("102. (Line feed end value) Failed to match strings: %q %q", output, values)
+		}
+	}
+}
+
+func TestReplaceValueTransform(t *testing.T) {
+	str := "a (FOO) b [Bar] c {KEEP} d (Skip)"
+	expected := "a (X) b [bar] c {keep} d (Skip)"
+
+	for _, size := range []int{1, 4, 64} {
+		for _, parallel := range []bool{false, true} {
+			rep := New(strings.NewReader(str), delimiters)
+			rep.SetBufferSize(size, 64)
+			rep.SetReplaceRange(1, 3)
+			rep.SetValueTransform(bytes.ToLower)
+
+			output := ""
+			var values []string
+
+			mapFunc := func(data []byte, atEOF bool) {
+				output = output + string(data)
+			}
+			filterFunc := func(value []byte) []byte {
+				values = append(values, string(value))
+
+				if string(value) == "foo" {
+					return []byte("X")
+				}
+
+				return value
+			}
+
+			if parallel {
+				rep.ReplaceFilterWithParallel(mapFunc, filterFunc, true, 1)
+			} else {
+				rep.ReplaceFilterWith(mapFunc, filterFunc, true)
+			}
+
+			if output != expected {
+				t.Fatalf("103. (ValueTransform) Failed to match strings: %q", output)
+			}
+
+			// filters see the transformed values
+			if strings.Join(values, "|") != "foo|bar|keep" {
+				t.Fatalf("103. (ValueTransform) Failed to match values: %q", values)
+			}
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		events, errc := rep.Events(context.Background(), []byte("REPL"))
+
+		output := ""
+		var values []string
+
+		for event := range events {
+			output = output + string(event.Data)
+
+			if event.Replaced {
+				values = append(values, string(event.Value))
+			}
+		}
+
+		if err := <-errc; err != nil {
+			t.Fatalf("104. (Events) Failed to finish: %v", err)
+		}
+
+		if output != "REPL ipsum dolor REPL magna REPL varius REPL." {
+			t.Fatalf("104. (Events) Failed to match strings: %q", output)
+		}
+
+		if strings.Join(values, "|") != "Lorem ( | nam risus | suscipit. | sapien " {
+			t.Fatalf("104. (Events) Failed to match values: %q", values)
+		}
+	}
+
+	// Abandoning the events stops the scanning once the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &countingReader{reader: strings.NewReader(strings.Repeat(STR, 1000))}
+	rep := New(r, delimiters)
+	rep.SetBufferSize(16, 64)
+
+	events, errc := rep.Events(ctx, []byte("REPL"))
+	<-events
+	cancel()
+
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("104. (Events) Failed to return the context error: %v", err)
+	}
+
+	if _, ok := <-events; ok {
+		t.Fatal("104. (Events) Failed to close the events channel!")
+	}
+
+	if r.count >= len(STR)*1000 {
+		t.Fatal("104. (Events) Failed to stop scanning!")
+	}
+}
+
+func TestReplaceStartAtBufferTail(t *testing.T) {
+	dels := Delimiters("<<<", ">>>")
+
+	for shift := 0; shift < 8; shift++ {
+		str := strings.Repeat("x", shift) + "ab<<<value>>>cd<<<"
+		expected := strings.Repeat("x", shift) + "abREPLcd<<<"
+
+		for size := 1; size <= 8; size++ {
+			observer := &recordingObserver{}
+
+			rep := New(strings.NewReader(str), dels)
+			rep.SetBufferSize(size, 64)
+			rep.SetObserver(observer)
+
+			output := ""
+
+			rep.Replace([]byte("REPL"), func(data []byte, atEOF bool) {
+				output = output + string(data)
+
+				// A partial start delimiter is never emitted as text before its region is known
+				if !strings.HasPrefix(expected, output) {
+					t.Fatalf("105. (Start at buffer tail %d) Failed to keep the partial start: %q", size, output)
+				}
+			})
+
+			if output != expected {
+				t.Fatalf("105. (Start at buffer tail %d) Failed to match strings: %q", size, output)
+			}
+
+			// Every input byte is scanned exactly once
+			if observer.bytes != len(str) {
+				t.Fatalf("105. (Start at buffer tail %d) Failed to scan bytes once: %d", size, observer.bytes)
+			}
+		}
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input.txt")
+
+	if err := os.WriteFile(path, []byte(STR), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReplaceFile(path, delimiters, []byte("REPL")); err != nil {
+		t.Fatalf("106. (ReplaceFile) Failed to replace the file: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil || string(data) != "REPL ipsum dolor REPL magna REPL varius REPL." {
+		t.Fatalf("106. (ReplaceFile) Failed to match strings: %q", data)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Fatal("106. (ReplaceFile) Failed to keep the file permissions!")
+	}
+
+	// An unclosed region larger than the buffer fails, so the original file is kept
+	original := "text (" + strings.Repeat("x", 128*1024)
+
+	if err := os.WriteFile(path, []byte(original), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReplaceFile(path, delimiters, []byte("REPL")); !errors.Is(err, ErrRegionTooLong) {
+		t.Fatalf("106. (ReplaceFile) Failed to return the error: %v", err)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != original {
+		t.Fatal("106. (ReplaceFile) Failed to keep the original file!")
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatal("106. (ReplaceFile) Failed to remove the temporary file!")
+	}
+
+	if err := ReplaceFile(filepath.Join(dir, "missing.txt"), delimiters, []byte("REPL")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("106. (ReplaceFile) Failed to return the missing file error: %v", err)
+	}
+}
+
+func TestCountByDelimiter(t *testing.T) {
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(STR+" [a] (b) <c>"), delimiters)
+		rep.SetBufferSize(size, 64)
+
+		counts, err := rep.CountByDelimiter()
+
+		if err != nil || len(counts) != 3 || counts["[…]"] != 2 || counts["{…}"] != 1 || counts["(…)"] != 3 {
+			t.Fatalf("107. (CountByDelimiter) Failed to match the counts: %v", counts)
+		}
+	}
+}
+
+func TestReplaceEmptyReplacement(t *testing.T) {
+	str := "a (b) c () d [e]"
+
+	for _, size := range []int{1, 4, 64} {
+		cases := []struct {
+			name     string
+			run      func(rep *Redel, mapFunc ReplacementMapFunc)
+			expected string
+		}{
+			{"Replace", func(rep *Redel, mapFunc ReplacementMapFunc) {
+				rep.Replace([]byte{}, mapFunc)
+			}, "a  c  d "},
+			{"ReplaceFilter", func(rep *Redel, mapFunc ReplacementMapFunc) {
+				rep.ReplaceFilter(nil, mapFunc, func(value []byte) bool {
+					return string(value) != "e"
+				}, true)
+			}, "a () c () d [e]"},
+			{"ReplaceFilterWith", func(rep *Redel, mapFunc ReplacementMapFunc) {
+				rep.ReplaceFilterWith(mapFunc, func(value []byte) []byte {
+					return nil
+				}, false)
+			}, "a  c  d "},
+		}
+
+		for _, c := range cases {
+			rep := New(strings.NewReader(str), delimiters)
+			rep.SetBufferSize(size, 64)
+
+			output := ""
+
+			c.run(rep, func(data []byte, atEOF bool) {
+				output = output + string(data)
+			})
+
+			if output != c.expected {
+				t.Fatalf("108. (%s + empty replacement) Failed to match strings: %q", c.name, output)
+			}
+		}
+	}
+}
+
+func TestReplaceAnchors(t *testing.T) {
+	header := Delimiter{Start: []byte("<h>"), End: []byte("</h>"), AnchorStart: true}
+	footer := Delimiter{Start: []byte("("), End: []byte(")"), AnchorEnd: true}
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"<h>title</h> text <h>again</h> (a) (b)", "X text <h>again</h> (a) X"},
+		{" <h>title</h> text (a) (b) ", " <h>title</h> text (a) (b) "},
+		{"<h>title</h>", "X"},
+		{"(a)", "X"},
+		{"(a (b)", "X"},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.input), []Delimiter{header, footer})
+			rep.SetBufferSize(size, 64)
+
+			output, err := rep.ReplaceAll([]byte("X"))
+
+			if err != nil || string(output) != c.expected {
+				t.Fatalf("109. (Anchors) Failed to match strings: %q", output)
+			}
+		}
+	}
+}
+
+func TestReplaceWithMapping(t *testing.T) {
+	for _, replacement := range []string{"", "X", "LONGER REPLACEMENT"} {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(STR), delimiters)
+			rep.SetBufferSize(size, 64)
+
+			output, mapping, err := rep.ReplaceWithMapping([]byte(replacement))
+
+			if err != nil || len(mapping) != 4 {
+				t.Fatalf("110. (ReplaceWithMapping) Failed to map the regions: %v", mapping)
+			}
+
+			expected, _ := New(strings.NewReader(STR), delimiters).ReplaceAll([]byte(replacement))
+
+			if !bytes.Equal(output, expected) {
+				t.Fatalf("110. (ReplaceWithMapping) Failed to match strings: %q", output)
+			}
+
+			var inputEnd, outputEnd int64
+
+			for i, pair := range mapping {
+				region := STR[pair.InputStart:pair.InputEnd]
+
+				if !strings.ContainsAny(region[0:1], "({[") || !strings.ContainsAny(region[len(region)-1:], ")}]") {
+					t.Fatalf("110. (ReplaceWithMapping) Failed to match the input region %d: %q", i, region)
+				}
+
+				if string(output[pair.OutputStart:pair.OutputEnd]) != replacement {
+					t.Fatalf("110. (ReplaceWithMapping) Failed to match the output region %d!", i)
+				}
+
+				// Text between regions is mapped unchanged
+				if STR[inputEnd:pair.InputStart] != string(output[outputEnd:pair.OutputStart]) {
+					t.Fatalf("110. (ReplaceWithMapping) Failed to map the text before the region %d!", i)
+				}
+
+				inputEnd, outputEnd = pair.InputEnd, pair.OutputEnd
+			}
+
+			if STR[inputEnd:] != string(output[outputEnd:]) {
+				t.Fatal("110. (ReplaceWithMapping) Failed to map the trailing text!")
+			}
+
+			if mapping[1].InputStart != int64(strings.Index(STR, "[")) || mapping[1].InputEnd != int64(strings.Index(STR, "]")+1) {
+				t.Fatalf("110. (ReplaceWithMapping) Failed to match the input offsets: %v", mapping[1])
+			}
+		}
+	}
+}
+
+func TestReplaceRepeatingSymmetricDelimiter(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+		values   []string
+	}{
+		{"|a|b|c|", "XbX", []string{"a", "c"}},
+		{"|a|b|c", "Xb|c", []string{"a"}},
+		{"||||", "XX", []string{"", ""}},
+		{"x|a||b|y", "xXXy", []string{"a", "b"}},
+	}
+
+	for _, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			rep := New(strings.NewReader(c.input), Delimiters("|", "|"))
+			rep.SetBufferSize(size, 64)
+
+			output, matches, err := rep.ReplaceAllWithMatches([]byte("X"))
+
+			if err != nil || string(output) != c.expected || len(matches) != len(c.values) {
+				t.Fatalf("111. (Repeating symmetric delimiter) Failed to match strings: %q", output)
+			}
+
+			for i, match := range matches {
+				if string(match.Value) != c.values[i] {
+					t.Fatalf("111. (Repeating symmetric delimiter) Failed to match the value: %q", match.Value)
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentInstances(t *testing.T) {
+	// A shared slice with spare capacity must not be modified by instances adding delimiters
+	shared := make([]Delimiter, len(delimiters), len(delimiters)+8)
+	copy(shared, delimiters)
+
+	errc := make(chan error, 32)
+
+	for i := 0; i < cap(errc); i++ {
+		go func(i int) {
+			str := strings.Repeat(STR+" <"+strconv.Itoa(i)+">", 50)
+
+			rep := New(strings.NewReader(str), shared)
+			rep.SetBufferSize(8, 64)
+
+			if err := rep.AddDelimiter([]byte("<"), []byte(">")); err != nil {
+				errc <- err
+				return
+			}
+
+			output, err := rep.ReplaceAll([]byte("X"))
+
+			if err == nil && string(output) != strings.Repeat("X ipsum dolor X magna X varius X. X", 50) {
+				err = fmt.Errorf("unexpected output %q", output)
+			}
+
+			errc <- err
+		}(i)
+	}
+
+	for i := 0; i < cap(errc); i++ {
+		if err := <-errc; err != nil {
+			t.Fatalf("112. (Concurrency) Failed to replace concurrently: %v", err)
+		}
+	}
+
+	if len(shared) != len(delimiters) || len(shared[:cap(shared)][len(delimiters)].Start) != 0 {
+		t.Fatal("112. (Concurrency) Failed to keep the shared delimiters unchanged!")
+	}
+}
+
+func TestReplaceUnclosedStartFullBuffer(t *testing.T) {
+	// An unclosed start delimiter followed by more regions than the maximum buffer size
+	str := "a ( b " + strings.Repeat("text [x] more ", 10000) + "end"
+	expected := "a ( b " + strings.Repeat("text X more ", 10000) + "end"
+
+	// The scanning stops by default, so the caller knows the remaining regions are not replaced
+	var buf bytes.Buffer
+
+	if _, err := Replace(&buf, strings.NewReader(str), delimiters, []byte("X")); !errors.Is(err, ErrRegionTooLong) {
+		t.Fatalf("113. (Unclosed start) Failed to return the region too long error: %v", err)
+	}
+
+	rep := New(strings.NewReader(str), delimiters)
+	rep.SetOversizedAsText(true)
+
+	if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != expected {
+		t.Fatalf("113. (Unclosed start + oversized as text) Failed to match strings: %v", err)
+	}
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetBufferSize(size, 128)
+		rep.SetOversizedAsText(true)
+
+		if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != expected {
+			t.Fatalf("113. (Unclosed start %d) Failed to match strings: %v", size, err)
+		}
+	}
+
+	// A longer start delimiter at the same position which is never closed is given up as well
+	dels := []Delimiter{
+		{Start: []byte("<"), End: []byte(">")},
+		{Start: []byte("<<"), End: []byte(">>")},
+	}
+
+	str = "<<a> " + strings.Repeat("b <c> ", 100)
+	expected = "X " + strings.Repeat("b X ", 100)
+
+	for _, size := range []int{1, 4, 64} {
+		rep := New(strings.NewReader(str), dels)
+		rep.SetBufferSize(size, 128)
+		rep.SetOversizedAsText(true)
+
+		if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != expected {
+			t.Fatalf("113. (Unclosed longest start %d) Failed to match strings: %q %v", size, output, err)
+		}
+
+		rep = New(strings.NewReader(str), dels)
+		rep.SetBufferSize(size, 128)
+
+		if _, err := rep.ReplaceAll([]byte("X")); !errors.Is(err, ErrRegionTooLong) {
+			t.Fatalf("113. (Unclosed longest start %d) Failed to return the region too long error: %v", size, err)
+		}
+	}
+}
+
+func TestReplacePartsOptions(t *testing.T) {
+	str := "a (x) (y)  [z] b (w)"
+
+	var matched []string
+	observer := &recordingObserver{}
+
+	newRep := func() *Redel {
+		matched = nil
+		observer.replaces = nil
+
+		rep := New(strings.NewReader(str), delimiters)
+		rep.SetBufferSize(4, 64)
+		rep.SetCollapseAdjacent(true)
+		rep.SetObserver(observer)
+		rep.SetMatchFunc(func(value []byte, replacement []byte) {
+			matched = append(matched, string(value)+"="+string(replacement))
+		})
+
+		return rep
+	}
+
+	expected, err := newRep().ReplaceAll([]byte("R"))
+
+	if err != nil || string(expected) != "a R b R" {
+		t.Fatalf("114. (Parts options) Failed to match strings: %q", expected)
+	}
+
+	expectedMatched := strings.Join(matched, "|")
+	expectedReplaces := strings.Join(observer.replaces, "|")
+
+	output := ""
+	var replaced []string
+
+	err = newRep().ReplaceParts([]byte("R"), func(data []byte) {
+		output += string(data)
+	}, func(value []byte, replacement []byte) {
+		replaced = append(replaced, string(value))
+		output += string(replacement)
+	})
+
+	if err != nil || output != string(expected) || strings.Join(replaced, "|") != "x|w" {
+		t.Fatalf("114. (ReplaceParts + options) Failed to match strings: %q %q", output, replaced)
+	}
+
+	if strings.Join(matched, "|") != expectedMatched || strings.Join(observer.replaces, "|") != expectedReplaces {
+		t.Fatal("114. (ReplaceParts + options) Failed to call the match function and the observer!")
+	}
+
+	mapped, mapping, err := newRep().ReplaceWithMapping([]byte("R"))
+
+	if err != nil || string(mapped) != string(expected) || len(mapping) != 2 {
+		t.Fatalf("114. (ReplaceWithMapping + options) Failed to match strings: %q", mapped)
+	}
+
+	if strings.Join(matched, "|") != expectedMatched || strings.Join(observer.replaces, "|") != expectedReplaces {
+		t.Fatal("114. (ReplaceWithMapping + options) Failed to call the match function and the observer!")
+	}
+
+	events, errc := newRep().Events(context.Background(), []byte("R"))
+	output = ""
+
+	for event := range events {
+		output += string(event.Data)
+	}
+
+	if err := <-errc; err != nil || output != string(expected) {
+		t.Fatalf("114. (Events + options) Failed to match strings: %q", output)
+	}
+
+	if strings.Join(matched, "|") != expectedMatched || strings.Join(observer.replaces, "|") != expectedReplaces {
+		t.Fatal("114. (Events + options) Failed to call the match function and the observer!")
+	}
+}
+
+func TestReplacePartsEmptyValuesAndTrailingNewline(t *testing.T) {
+	str := "a () b ( ) c [x]\n\n(y) d\r\n\r\n"
+
+	cases := []struct {
+		configure func(rep *Redel)
+		expected  string
+	}{
+		{func(rep *Redel) {
+			rep.SetEmptyValueReplacement([]byte("E"))
+			rep.SetWhitespaceAsEmpty(true)
+			rep.SetEnsureTrailingNewline(TrailingNewlineAlways)
+		}, "a E b E c R\n\nR d\n"},
+		{func(rep *Redel) {
+			rep.SetSkipEmptyValues(true)
+			rep.SetEnsureTrailingNewline(TrailingNewlineNever)
+		}, "a () b R c R\n\nR d"},
+	}
+
+	for i, c := range cases {
+		for _, size := range []int{1, 4, 64} {
+			newRep := func() *Redel {
+				rep := New(strings.NewReader(str), delimiters)
+				rep.SetBufferSize(size, 64)
+				c.configure(rep)
+
+				return rep
+			}
+
+			expected, err := newRep().ReplaceAll([]byte("R"))
+
+			if err != nil || string(expected) != c.expected {
+				t.Fatalf("115. (Parts empty values %d-%d) Failed to match strings: %q", i, size, expected)
+			}
+
+			output := ""
+
+			err = newRep().ReplaceParts([]byte("R"), func(data []byte) {
+				output += string(data)
+			}, func(value []byte, replacement []byte) {
+				output += string(replacement)
+			})
+
+			if err != nil || output != c.expected {
+				t.Fatalf("115. (ReplaceParts + empty values %d-%d) Failed to match strings: %q", i, size, output)
+			}
+
+			mapped, _, err := newRep().ReplaceWithMapping([]byte("R"))
+
+			if err != nil || string(mapped) != c.expected {
+				t.Fatalf("115. (ReplaceWithMapping + empty values %d-%d) Failed to match strings: %q", i, size, mapped)
+			}
+
+			events, errc := newRep().Events(context.Background(), []byte("R"))
+			output = ""
+
+			for event := range events {
+				output += string(event.Data)
+			}
+
+			if err := <-errc; err != nil || output != c.expected {
+				t.Fatalf("115. (Events + empty values %d-%d) Failed to match strings: %q", i, size, output)
+			}
+		}
+	}
+}
+
+func TestScannerSingleRun(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader(STR))
+	rep := NewFromScanner(scanner, delimiters)
+
+	if count, err := rep.Count(); err != nil || count != 4 {
+		t.Fatalf("116. (Scanner single run) Failed to count: %d %v", count, err)
+	}
+
+	// The scanner can't be rewound, so further runs fail instead of panicking
+	if _, err := rep.ReplaceAll([]byte("X")); err != ErrScannerUsed {
+		t.Fatalf("116. (Scanner single run) Failed to return the used scanner error: %v", err)
+	}
+
+	output := ""
+
+	rep.Replace([]byte("X"), func(data []byte, atEOF bool) {
+		output = output + string(data)
+	})
+
+	if output != "" {
+		t.Fatal("116. (Scanner single run) Failed to skip a further run!")
+	}
+
+	rep.Reset(strings.NewReader(STR))
+
+	if output, err := rep.ReplaceAll([]byte("X")); err != nil || string(output) != "X ipsum dolor X magna X varius X." {
+		t.Fatalf("116. (Scanner single run) Failed to run after Reset: %q %v", output, err)
+	}
+}
+
+func TestReplaceRegionFillingBuffer(t *testing.T) {
+	for max := 3; max <= 8; max++ {
+		region := "(" + strings.Repeat("v", max-2) + ")"
+
+		for _, str := range []string{region + "yy", "x" + region + "yy", region + region, "ab" + region, region} {
+			expected := strings.ReplaceAll(str, region, "R")
+
+			for size := 1; size <= max; size++ {
+				rep := New(strings.NewReader(str), delimiters)
+				rep.SetBufferSize(size, max)
+
+				output := ""
+				eofCalls := 0
+
+				rep.Replace([]byte("R"), func(data []byte, atEOF bool) {
+					output = output + string(data)
+
+					if atEOF {
+						eofCalls++
+					}
+				})
+
+				if output != expected || eofCalls != 1 {
+					t.Fatalf("118. (Region filling buffer %q %d-%d) Failed to match strings: %q %d", str, size, max, output, eofCalls)
+				}
+			}
+		}
+	}
+
+	// A region larger than the maximum buffer size still fails unless it's emitted as text
+	str := "xx(abc)yy(d)"
+
+	rep := New(strings.NewReader(str), delimiters)
+	rep.SetBufferSize(1, 4)
+
+	if _, err := rep.ReplaceAll([]byte("R")); !errors.Is(err, ErrRegionTooLong) {
+		t.Fatalf("118. (Region filling buffer) Failed to return the region too long error: %v", err)
+	}
+
+	rep = New(strings.NewReader(str), delimiters)
+	rep.SetBufferSize(1, 4)
+	rep.SetOversizedAsText(true)
+
+	if output, err := rep.ReplaceAll([]byte("R")); err != nil || string(output) != "xx(abc)yyR" {
+		t.Fatalf("118. (Region filling buffer + oversized as text) Failed to match strings: %q %v", output, err)
+	}
+}
+
+func TestReplaceFilterWithParallelCallbacks(t *testing.T) {
+	filterFunc := func(value []byte) []byte {
+		return bytes.ToUpper(value)
+	}
+
+	replacedMessages := func(logger *recordingLogger) string {
+		var messages []string
+
+		for _, message := range logger.messages {
+			if strings.Contains(message, "replaced with") {
+				messages = append(messages, message)
+			}
+		}
+
+		return strings.Join(messages, "|")
+	}
+
+	for _, size := range []int{1, 4, 64} {
+		observer := &recordingObserver{}
+		logger := &recordingLogger{}
+
+		rep := New(strings.NewReader(STR), delimiters)
+		rep.SetBufferSize(size, 0)
+		rep.SetObserver(observer)
+		rep.SetLogger(logger)
+
+		output := ""
+
+		rep.ReplaceFilterWith(func(data []byte, atEOF bool) {
+			output = output + string(data)
+		}, filterFunc, true)
+
+		for concurrency := 1; concurrency <= 4; concurrency++ {
+			observerParallel := &recordingObserver{}
+			loggerParallel := &recordingLogger{}
+
+			rep := New(strings.NewReader(STR), delimiters)
+			rep.SetBufferSize(size, 0)
+			rep.SetObserver(observerParallel)
+			rep.SetLogger(loggerParallel)
+
+			outputParallel := ""
+
+			rep.ReplaceFilterWithParallel(func(data []byte, atEOF bool) {
+				outputParallel = outputParallel + string(data)
+			}, filterFunc, true, concurrency)
+
+			if outputParallel != output {
+				t.Fatalf("119. (ReplaceFilterWithParallel %d-%d) Failed to match strings: %q", size, concurrency, outputParallel)
+			}
+
+			if strings.Join(observerParallel.replaces, "|") != strings.Join(observer.replaces, "|") ||
+				strings.Join(observerParallel.matches, "|") != strings.Join(observer.matches, "|") ||
+				observerParallel.bytes != observer.bytes {
+				t.Fatalf("119. (ReplaceFilterWithParallel + observer %d-%d) Failed to match observer calls!", size, concurrency)
+			}
+
+			if replacedMessages(loggerParallel) != replacedMessages(logger) || len(loggerParallel.messages) != len(logger.messages) {
+				t.Fatalf("119. (ReplaceFilterWithParallel + logger %d-%d) Failed to match logged messages!", size, concurrency)
+			}
+		}
+	}
+}